@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+)
+
+const (
+	Blue  = "blue"
+	Red   = "red"
+	White = "white"
+	Black = "black"
+	Green = "green" // the third team, or agents in duet
+)
+
+// the classic board is 5x5, others may be anywhere between MinSize and MaxSize on each side
+const (
+	Size    = 5
+	MinSize = 3
+	MaxSize = 8
+)
+
+var ErrInvalidCell = errors.New("invalid cell")
+
+type Cell struct {
+	Word   string
+	Color  string
+	IsOpen bool
+
+	// in pictures games, the picture the cell shows instead of the word,
+	// a path inside the pictures directory, the word is its name then
+	Image string `json:",omitempty"`
+
+	// IDs of the operatives who want to open the cell
+	Proposals []string `json:",omitempty"`
+
+	// in duet, the color of the cell on the key card of each side,
+	// and the sides whose key card is known to have a bystander here
+	Keys       map[string]string `json:",omitempty"`
+	Bystanders []string          `json:",omitempty"`
+}
+
+// Board is a list of rows of the same length
+type Board [][]Cell
+
+// NewBoard lays the words out on a board of the given size and deals a random key card over them,
+// teams are the colors of the teams in the order they take turns, the first one gets key.First words.
+// There must be a word for every cell. The same source of randomness deals the same key card
+func NewBoard(rng *rand.Rand, rows, cols int, words []string, key KeyCard, teams []string) Board {
+	// shuffle word colors, they go in a fixed order before that to keep the shuffle reproducible
+	colors := make([]string, 0, rows*cols)
+	add := func(color string, n int) {
+		for range n {
+			colors = append(colors, color)
+		}
+	}
+	for i, n := range []int{key.First, key.Second, key.Third}[:len(teams)] {
+		add(teams[i], n)
+	}
+	add(White, key.Bystanders)
+	add(Black, key.Assassins)
+	// whatever the key card leaves out are bystanders
+	for len(colors) < rows*cols {
+		colors = append(colors, White)
+	}
+	rng.Shuffle(len(colors), func(i, j int) {
+		colors[i], colors[j] = colors[j], colors[i]
+	})
+
+	b := make(Board, rows)
+	var idx int
+	for i := range b {
+		b[i] = make([]Cell, cols)
+		for j := range b[i] {
+			b[i][j].Word = words[idx]
+			b[i][j].Color = colors[idx]
+			idx++
+		}
+	}
+	return b
+}
+
+// Rows returns the number of rows of the board
+func (b Board) Rows() int {
+	return len(b)
+}
+
+// Cols returns the number of cells in a row of the board
+func (b Board) Cols() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+// Cell returns the cell at the given position, checking the bounds
+func (b Board) Cell(row, col int) (*Cell, error) {
+	if row < 0 || row >= b.Rows() || col < 0 || col >= b.Cols() {
+		return nil, ErrInvalidCell
+	}
+	return &b[row][col], nil
+}
+
+// copy returns a board of its own with the same cells
+func (b Board) copy() Board {
+	c := make(Board, len(b))
+	for i := range b {
+		c[i] = slices.Clone(b[i])
+		for j := range c[i] {
+			c[i][j].Bystanders = slices.Clone(c[i][j].Bystanders)
+		}
+	}
+	return c
+}
+
+// clearProposals takes back every vote on the board
+func (b Board) clearProposals() {
+	for i := range b {
+		for j := range b[i] {
+			b[i][j].Proposals = nil
+		}
+	}
+}
+
+// Count returns the number of cells of the given color that are still closed
+func (b Board) Count(color string) int {
+	var n int
+	for i := range b {
+		for j := range b[i] {
+			if b[i][j].Color == color && !b[i][j].IsOpen {
+				n++
+			}
+		}
+	}
+	return n
+}
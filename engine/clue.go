@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidClue = errors.New("invalid clue")
+
+// DefaultStemLength is how many first letters two words have to share
+// to be considered forms of the same word, unless the rules say otherwise
+const DefaultStemLength = 5
+
+// ClueRules configures which clues the spymasters are allowed to give.
+// The zero value is the strict ruleset of the original game
+type ClueRules struct {
+	// Phrases allows clues of more than one word
+	Phrases bool
+
+	// BoardWords allows clues that contain, are contained in or share the stem
+	// with the words still on the board
+	BoardWords bool
+
+	// StemLength overrides DefaultStemLength
+	StemLength int `json:",omitempty"`
+}
+
+// ValidateClue checks the clue against the rules and the board. The errors it returns
+// wrap ErrInvalidClue and are meant to be shown to the spymaster as they are
+func (g *Game) ValidateClue(team *Team, word string, number int) error {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return fmt.Errorf("%w: the clue is empty", ErrInvalidClue)
+	}
+	if !g.ClueRules.Phrases && len(strings.FieldsFunc(word, separator)) > 1 {
+		return fmt.Errorf("%w: the clue must be a single word", ErrInvalidClue)
+	}
+	if number != Unlimited && (number < 0 || number > team.WordsLeft) {
+		return fmt.Errorf("%w: the number must be between 0 and %d or unlimited", ErrInvalidClue, team.WordsLeft)
+	}
+
+	if !g.ClueRules.BoardWords {
+		stem := g.ClueRules.StemLength
+		if stem <= 0 {
+			stem = DefaultStemLength
+		}
+		for i := range g.Board {
+			for _, cell := range g.Board[i] {
+				if cell.IsOpen {
+					continue
+				}
+				if related(word, cell.Word, stem) {
+					return fmt.Errorf("%w: %q is too close to %q on the board", ErrInvalidClue, word, cell.Word)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// separator splits the clue into words, hyphenated words count as one
+func separator(r rune) bool {
+	return unicode.IsSpace(r) || r == '_'
+}
+
+// words shorter than this are not looked for inside the others,
+// otherwise "of" from "Lord of the Rings" would forbid "often"
+const minSubstring = 3
+
+// related reports whether any word of the clue is a substring of any word of the board entry
+// or the other way around, or if they share the stem
+func related(clue, entry string, stem int) bool {
+	for _, c := range strings.FieldsFunc(strings.ToLower(clue), separator) {
+		for _, e := range strings.FieldsFunc(strings.ToLower(entry), separator) {
+			if c == e || contains(c, e) || contains(e, c) {
+				return true
+			}
+			if commonPrefix(c, e) >= stem {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(s, substr string) bool {
+	return utf8.RuneCountInString(substr) >= minSubstring && strings.Contains(s, substr)
+}
+
+// commonPrefix returns the number of leading letters the words have in common
+func commonPrefix(a, b string) int {
+	var n int
+	for a != "" && b != "" {
+		ra, sa := utf8.DecodeRuneInString(a)
+		rb, sb := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			break
+		}
+		a, b = a[sa:], b[sb:]
+		n++
+	}
+	return n
+}
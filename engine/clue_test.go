@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateClue(t *testing.T) {
+	words := [][]string{
+		{"white", "snow", "Lord of the Rings"},
+		{"apple", "river", "bridge"},
+		{"castle", "ocean", "moon"},
+	}
+	tests := []struct {
+		name   string
+		rules  ClueRules
+		word   string
+		number int
+		// the board word to open before the clue
+		open string
+		ok   bool
+	}{
+		{name: "unrelated word", word: "tree", number: 1, ok: true},
+		{name: "empty", word: "  ", number: 1},
+		{name: "board word", word: "white", number: 1},
+		{name: "board word in capitals", word: "WHITE", number: 1},
+		{name: "inside a board word", word: "whi", number: 1},
+		{name: "too short to be looked for", word: "wh", number: 1, ok: true},
+		{name: "contains a board word", word: "snowman", number: 1},
+		{name: "a word of a board entry", word: "rings", number: 1},
+		{name: "short word of a board entry", word: "often", number: 1, ok: true},
+		{name: "shares the stem", word: "bridging", number: 1},
+		{name: "shares less than the stem", word: "bridging", number: 1, rules: ClueRules{StemLength: 6}, ok: true},
+		{name: "board words allowed", word: "snowman", number: 1, rules: ClueRules{BoardWords: true}, ok: true},
+		{name: "opened board word", word: "whi", number: 1, open: "white", ok: true},
+		{name: "hyphenated", word: "ice-cream", number: 1, ok: true},
+		{name: "spaced", word: "ice cream", number: 1},
+		{name: "underscored", word: "ice_cream", number: 1},
+		{name: "phrase", word: "ice cream", number: 1, rules: ClueRules{Phrases: true}, ok: true},
+		{name: "phrase with a board word", word: "snow day", number: 1, rules: ClueRules{Phrases: true}},
+		{name: "zero", word: "tree", number: 0, ok: true},
+		{name: "∞", word: "tree", number: Unlimited, ok: true},
+		{name: "all the words left", word: "tree", number: 3, ok: true},
+		{name: "more than the words left", word: "tree", number: 4},
+		{name: "negative", word: "tree", number: -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, _ := testGame(t)
+			g.ClueRules = tt.rules
+			for i, row := range words {
+				for j, word := range row {
+					g.Board[i][j].Word = word
+					g.Board[i][j].IsOpen = word == tt.open
+				}
+			}
+			err := g.ValidateClue(g.Team(Blue), tt.word, tt.number)
+			if tt.ok && err != nil {
+				t.Errorf("%q %d: %v, want it accepted", tt.word, tt.number, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidClue) {
+				t.Errorf("%q %d: got %v, want %v", tt.word, tt.number, err, ErrInvalidClue)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"math/rand"
+	"slices"
+)
+
+// game modes
+const (
+	Classic = ""     // two teams compete to find their words first
+	Duet    = "duet" // two players find the agents together
+)
+
+// In duet the two sides are called Blue and Red like the teams of the classic game,
+// each has a single player who gives clues from their own key card and guesses the clues of the other.
+// A key card has 9 agents, 3 assassins and 13 bystanders, and the two cards share some of them,
+// so that there are 15 agents to find in total
+const (
+	DuetSize   = 5
+	DuetTokens = 9 // turns the players have to find all the agents
+)
+
+// duetKeys is how the key cards of the two sides overlap,
+// every pair is the color of a cell on the first and the second card
+var duetKeys = []struct {
+	first, second string
+	n             int
+}{
+	{Green, Green, 3},
+	{Green, White, 5},
+	{Green, Black, 1},
+	{Black, Green, 1},
+	{Black, Black, 1},
+	{Black, White, 1},
+	{White, Green, 5},
+	{White, Black, 1},
+	{White, White, 7},
+}
+
+// NewDuetBoard lays the words out on a 5x5 board and deals the key cards of both sides over them.
+// Color of a cell is what it is to the game as a whole: an agent if it is one on either card,
+// otherwise the assassin if it is one on either card, otherwise a bystander
+func NewDuetBoard(rng *rand.Rand, words []string) Board {
+	var keys [][2]string
+	for _, k := range duetKeys {
+		for range k.n {
+			keys = append(keys, [2]string{k.first, k.second})
+		}
+	}
+	rng.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+
+	b := make(Board, DuetSize)
+	var idx int
+	for i := range b {
+		b[i] = make([]Cell, DuetSize)
+		for j := range b[i] {
+			key := keys[idx]
+			color := White
+			switch {
+			case key[0] == Green || key[1] == Green:
+				color = Green
+			case key[0] == Black || key[1] == Black:
+				color = Black
+			}
+			b[i][j] = Cell{
+				Word:  words[idx],
+				Color: color,
+				Keys:  map[string]string{Blue: key[0], Red: key[1]},
+			}
+			idx++
+		}
+	}
+	return b
+}
+
+// CountKey returns the number of closed cells that have the color on the key card of the side
+func (b Board) CountKey(side, color string) int {
+	var n int
+	for i := range b {
+		for j := range b[i] {
+			if b[i][j].Keys[side] == color && !b[i][j].IsOpen {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// KeyCard returns the board as the side sees it in duet: closed cells have the colors
+// of the side's key card, the agents that have been found are green for everyone
+func (b Board) KeyCard(side string) Board {
+	key := b.copy()
+	for i := range key {
+		for j := range key[i] {
+			if !key[i][j].IsOpen {
+				key[i][j].Color = key[i][j].Keys[side]
+			}
+		}
+	}
+	return key
+}
+
+// BoardFor returns the board the player should be shown. It is the same for everyone
+// in the classic game, but in duet every player has a key card of their own until the game is over
+func (g *Game) BoardFor(p *Player) Board {
+	if g.Mode != Duet || p == nil || g.Ended() {
+		return g.Board
+	}
+	return g.Board.KeyCard(p.Team)
+}
+
+// Guessers returns the players who guess the clues of the team:
+// its operatives, or the other side in duet
+func (g *Game) Guessers(color string) []*Player {
+	if g.Mode == Duet {
+		if other := g.Next(color); other != nil && other.Spymaster != nil {
+			return []*Player{other.Spymaster}
+		}
+		return nil
+	}
+	if team := g.Team(color); team != nil {
+		return team.Operatives
+	}
+	return nil
+}
+
+// guessing checks whether the player is the one to guess the current clue in duet
+// and returns the side that has given it
+func (g *Game) guessing(p *Player) (*Team, error) {
+	switch g.Phase {
+	case Lobby:
+		return nil, ErrNotReady
+	case Over:
+		return nil, ErrGameOver
+	}
+	giver := g.Team(g.Turn)
+	guesser := g.Next(g.Turn).Spymaster
+	if g.Phase != Guessing || p == nil || guesser == nil || guesser.ID != p.ID {
+		return nil, ErrNotYourTurn
+	}
+	return giver, nil
+}
+
+// guessDuet opens the cell if it is an agent on the key card of the side that has given the clue.
+// A bystander ends the turn and stays closed for the other side's clues, the assassin ends the game
+func (g *Game) guessDuet(p *Player, row, col int) ([]Event, error) {
+	giver, err := g.guessing(p)
+	if err != nil {
+		return nil, err
+	}
+	cell, err := g.Board.Cell(row, col)
+	if err != nil {
+		return nil, err
+	}
+	// a bystander of the giver's card has already been found here
+	if cell.IsOpen || slices.Contains(cell.Bystanders, giver.Color) {
+		return nil, ErrCellOpen
+	}
+
+	color := cell.Keys[giver.Color]
+	g.record(Move{
+		Kind:     MoveGuess,
+		PlayerID: p.ID,
+		Nickname: p.Nickname,
+		Team:     p.Team,
+		Row:      row,
+		Col:      col,
+		Word:     cell.Word,
+		Color:    color,
+	})
+
+	switch color {
+	case Green:
+		cell.IsOpen = true
+		cell.Color = Green
+		agentsLeft := 0
+		for _, side := range g.Teams {
+			side.WordsLeft = g.Board.CountKey(side.Color, Green)
+			agentsLeft += side.WordsLeft
+		}
+		events := []Event{CellOpened{Row: row, Col: col, Cell: *cell}}
+		if agentsLeft == 0 {
+			return g.finish(events, Green), nil
+		}
+		// the giver has no agents left to give clues for
+		if giver.WordsLeft == 0 {
+			return g.endTurn(events), nil
+		}
+		return events, nil
+	case Black:
+		return g.finish(nil, Black), nil
+	default:
+		cell.Bystanders = append(cell.Bystanders, giver.Color)
+		return g.endTurn([]Event{CellMarked{Row: row, Col: col, Cell: *cell}}), nil
+	}
+}
+
+// endGuessingDuet passes the turn on behalf of the guessing side
+func (g *Game) endGuessingDuet(p *Player) ([]Event, error) {
+	if _, err := g.guessing(p); err != nil {
+		return nil, err
+	}
+	g.record(Move{
+		Kind:     MoveEndGuessing,
+		PlayerID: p.ID,
+		Nickname: p.Nickname,
+		Team:     p.Team,
+	})
+	return g.endTurn(nil), nil
+}
+
+// nextDuet spends a token on the turn that has ended and returns the side to give the next clue,
+// which is the other side unless it has no agents left on its card.
+// It reports false when the players have run out of tokens
+func (g *Game) nextDuet() (string, bool) {
+	g.TokensLeft--
+	if g.TokensLeft <= 0 {
+		return "", false
+	}
+	next := g.Next(g.Turn)
+	if next.WordsLeft == 0 {
+		return g.Turn, true
+	}
+	return next.Color, true
+}
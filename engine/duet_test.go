@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// testDuetBoard is a 3x3 duet board, every cell has its colors on the key cards of blue and red:
+//
+//	green/green  green/white  white/green
+//	black/white  white/black  white/white
+//	white/white  white/white  white/white
+//
+// so each side has two agents, one of them shared, and an assassin of its own
+func testDuetBoard() Board {
+	keys := [][][2]string{
+		{{Green, Green}, {Green, White}, {White, Green}},
+		{{Black, White}, {White, Black}, {White, White}},
+		{{White, White}, {White, White}, {White, White}},
+	}
+	board := make(Board, len(keys))
+	for i, row := range keys {
+		board[i] = make([]Cell, len(row))
+		for j, key := range row {
+			color := White
+			switch {
+			case key[0] == Green || key[1] == Green:
+				color = Green
+			case key[0] == Black || key[1] == Black:
+				color = Black
+			}
+			board[i][j] = Cell{
+				Word:  fmt.Sprint("word", i*3+j),
+				Color: color,
+				Keys:  map[string]string{Blue: key[0], Red: key[1]},
+			}
+		}
+	}
+	return board
+}
+
+// testDuetGame starts a duet game on the test board with blue giving the first clue
+func testDuetGame(t *testing.T, tokens int) *Game {
+	t.Helper()
+	s := DefaultSettings()
+	s.Mode = Duet
+	s.Rows, s.Cols = 3, 3
+	s.FirstTeam = Blue
+	s.Tokens = tokens
+	g := New(testDuetBoard(), s)
+	for _, color := range []string{Blue, Red} {
+		if err := g.Seat(&Player{ID: color, Nickname: color, Team: color, Role: Spymaster}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// duetStep is a clue given by the side, a guess or the end of guessing by the side that guesses
+type duetStep struct {
+	kind     string
+	side     string
+	row, col int
+}
+
+func clue(side string) duetStep {
+	return duetStep{kind: MoveClue, side: side}
+}
+
+func guess(side string, row, col int) duetStep {
+	return duetStep{kind: MoveGuess, side: side, row: row, col: col}
+}
+
+func endGuessing(side string) duetStep {
+	return duetStep{kind: MoveEndGuessing, side: side}
+}
+
+func TestDuet(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens int
+		steps  []duetStep
+		// the state of the game after the steps
+		turn       string
+		phase      Phase
+		winner     string
+		tokensLeft int
+		blueLeft   int
+		redLeft    int
+	}{
+		{
+			name:   "assassin on the giver's card",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 1, 0)},
+			turn:   Blue, phase: Over, winner: Black, tokensLeft: 3, blueLeft: 2, redLeft: 2,
+		},
+		{
+			name:   "assassin on the other card",
+			tokens: 3,
+			// the assassin of red is a bystander to blue, but not the other way round
+			steps: []duetStep{clue(Blue), guess(Red, 1, 1), clue(Red), guess(Blue, 1, 1)},
+			turn:  Red, phase: Over, winner: Black, tokensLeft: 2, blueLeft: 2, redLeft: 2,
+		},
+		{
+			name:   "agent of the giver, bystander of the guesser",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 1)},
+			turn:   Blue, phase: Guessing, tokensLeft: 3, blueLeft: 1, redLeft: 2,
+		},
+		{
+			name:   "bystander of the giver, agent of the guesser",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 2)},
+			turn:   Red, phase: Giving, tokensLeft: 2, blueLeft: 2, redLeft: 2,
+		},
+		{
+			name:   "shared agent",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 0)},
+			turn:   Blue, phase: Guessing, tokensLeft: 3, blueLeft: 1, redLeft: 1,
+		},
+		{
+			name:   "out of tokens",
+			tokens: 2,
+			steps:  []duetStep{clue(Blue), endGuessing(Red), clue(Red), endGuessing(Blue)},
+			turn:   Red, phase: Over, winner: Black, tokensLeft: 0, blueLeft: 2, redLeft: 2,
+		},
+		{
+			name:   "the side with no agents left hands the turn over",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 0), guess(Red, 0, 1)},
+			turn:   Red, phase: Giving, tokensLeft: 2, blueLeft: 0, redLeft: 1,
+		},
+		{
+			name:   "the other side keeps giving clues",
+			tokens: 4,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 0), guess(Red, 0, 1), clue(Red), endGuessing(Blue)},
+			turn:   Red, phase: Giving, tokensLeft: 2, blueLeft: 0, redLeft: 1,
+		},
+		{
+			name:   "both cards cleared",
+			tokens: 3,
+			steps:  []duetStep{clue(Blue), guess(Red, 0, 0), guess(Red, 0, 1), clue(Red), guess(Blue, 0, 2)},
+			turn:   Red, phase: Over, winner: Green, tokensLeft: 2, blueLeft: 0, redLeft: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testDuetGame(t, tt.tokens)
+			for i, step := range tt.steps {
+				p := g.Team(step.side).Spymaster
+				var err error
+				switch step.kind {
+				case MoveClue:
+					_, err = g.GiveClue(p, "clue", 1)
+				case MoveGuess:
+					_, err = g.Propose(p, step.row, step.col)
+				case MoveEndGuessing:
+					_, err = g.EndGuessing(p)
+				}
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+			}
+			if g.Turn != tt.turn || g.Phase != tt.phase || g.Winner != tt.winner {
+				t.Errorf("turn %s, phase %d, winner %q, want %s, %d, %q", g.Turn, g.Phase, g.Winner, tt.turn, tt.phase, tt.winner)
+			}
+			if g.TokensLeft != tt.tokensLeft {
+				t.Errorf("%d tokens left, want %d", g.TokensLeft, tt.tokensLeft)
+			}
+			if blue, red := g.Team(Blue).WordsLeft, g.Team(Red).WordsLeft; blue != tt.blueLeft || red != tt.redLeft {
+				t.Errorf("agents left: blue %d, red %d, want %d, %d", blue, red, tt.blueLeft, tt.redLeft)
+			}
+		})
+	}
+}
+
+func TestDuetBystanders(t *testing.T) {
+	g := testDuetGame(t, 5)
+	if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 1); err != nil {
+		t.Fatal(err)
+	}
+	// a bystander on blue's card, the agent of red under it is still to be found
+	if _, err := g.Guess(g.Team(Red).Spymaster, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	cell := g.Board[0][2]
+	if cell.IsOpen || !slices.Equal(cell.Bystanders, []string{Blue}) {
+		t.Fatalf("open %v, bystanders %v, want it closed and marked for blue", cell.IsOpen, cell.Bystanders)
+	}
+
+	// red's clue can still lead blue to it
+	if _, err := g.GiveClue(g.Team(Red).Spymaster, "clue", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Guess(g.Team(Blue).Spymaster, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if cell := g.Board[0][2]; !cell.IsOpen || cell.Color != Green {
+		t.Errorf("open %v, color %s, want an open agent", cell.IsOpen, cell.Color)
+	}
+
+	// but blue's clues can't lead red to a bystander of blue's card again
+	g = testDuetGame(t, 5)
+	g.GiveClue(g.Team(Blue).Spymaster, "clue", 1)
+	g.Guess(g.Team(Red).Spymaster, 0, 2)
+	g.GiveClue(g.Team(Red).Spymaster, "clue", 1)
+	g.EndGuessing(g.Team(Blue).Spymaster)
+	g.GiveClue(g.Team(Blue).Spymaster, "clue", 1)
+	if _, err := g.Guess(g.Team(Red).Spymaster, 0, 2); err != ErrCellOpen {
+		t.Errorf("got %v, want %v", err, ErrCellOpen)
+	}
+}
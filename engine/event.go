@@ -0,0 +1,71 @@
+package engine
+
+// Event is something that has happened in the game as a result of a move.
+// The engine does not know how events reach the players, so it is up to
+// the caller to render and deliver them
+type Event interface {
+	event()
+}
+
+// TurnStarted means the team's spymaster should give a clue now
+type TurnStarted struct {
+	Team string
+}
+
+// ClueGiven means the team's operatives may start guessing
+type ClueGiven struct {
+	Clue Clue
+}
+
+// CellOpened is sent for every guessed cell, whatever its color
+type CellOpened struct {
+	Row  int
+	Col  int
+	Cell Cell
+}
+
+// CellProposed is sent when an operative votes for the cell or takes the vote back
+type CellProposed struct {
+	Row  int
+	Col  int
+	Cell Cell
+}
+
+// CellMarked is sent in duet when the guess turns out to be a bystander on the key card
+// of the side that has given the clue, the cell stays closed for the other side
+type CellMarked struct {
+	Row  int
+	Col  int
+	Cell Cell
+}
+
+// TurnEnded means the team is done guessing, either voluntarily or not
+type TurnEnded struct {
+	Team string
+}
+
+// TimedOut means the team has run out of time, the turn passes right after it
+type TimedOut struct {
+	Team string
+}
+
+// TeamEliminated means the team has hit the assassin in a game of three teams,
+// it doesn't take turns anymore
+type TeamEliminated struct {
+	Team string
+}
+
+// GameOver is the last event of the game
+type GameOver struct {
+	Winner string
+}
+
+func (TurnStarted) event()    {}
+func (ClueGiven) event()      {}
+func (CellOpened) event()     {}
+func (CellProposed) event()   {}
+func (CellMarked) event()     {}
+func (TurnEnded) event()      {}
+func (TimedOut) event()       {}
+func (TeamEliminated) event() {}
+func (GameOver) event()       {}
@@ -0,0 +1,505 @@
+// Package engine implements the rules of codenames as a plain state machine.
+// It has no idea about websockets or templates: every move returns the events
+// it produced, and the caller decides how to show them to the players
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	Operative = "o"
+	Spymaster = "s"
+)
+
+var (
+	ErrInvalidSeat = errors.New("invalid team or role")
+	ErrSeatTaken   = errors.New("seat is already taken")
+	ErrNotReady    = errors.New("not all seats are taken")
+	ErrBegun       = errors.New("game has already begun")
+	ErrGameOver    = errors.New("game is over")
+	ErrNotYourTurn = errors.New("not your turn")
+	ErrCellOpen    = errors.New("cell is already open")
+	ErrTeamFull    = errors.New("team has no more seats for operatives")
+)
+
+// Phase tells whose move the game is waiting for
+type Phase int
+
+const (
+	Lobby    Phase = iota // players are taking their seats
+	Giving                // spymaster of the current team gives a clue
+	Guessing              // operatives of the current team guess
+	Over
+)
+
+type Player struct {
+	ID       string
+	Nickname string
+	Team     string
+	Role     string
+}
+
+type Team struct {
+	Color      string
+	Operatives []*Player
+	Spymaster  *Player
+	WordsLeft  int
+
+	// the team has hit the assassin in a game of three teams and doesn't play anymore
+	Eliminated bool `json:",omitempty"`
+}
+
+// HasOperative reports whether the player is one of the team's operatives
+func (t *Team) HasOperative(p *Player) bool {
+	for _, o := range t.Operatives {
+		if o.ID == p.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Unlimited is the clue number that lets the operatives guess for as long as they are right
+const Unlimited = -1
+
+type Clue struct {
+	Team   string
+	Word   string
+	Number int
+}
+
+// Guesses returns how many guesses the clue gives: one more than the number,
+// or as many as the operatives like for zero and Unlimited
+func (c Clue) Guesses() int {
+	if c.Number == 0 || c.Number == Unlimited {
+		return Unlimited
+	}
+	return c.Number + 1
+}
+
+type Game struct {
+	Board Board
+
+	// the teams in the order they take turns, starting from any of them
+	Teams []*Team
+
+	Turn        string
+	Winner      string // the winning team, or Green or Black for winning or losing together in duet
+	Clue        *Clue
+	Phase       Phase
+	GuessesLeft int // Unlimited after a clue with zero or Unlimited
+
+	// the team that gives the first clue, it has more words to guess
+	First string
+
+	// turns left to find all the agents in duet
+	TokensLeft int
+
+	Settings
+
+	// when the current team runs out of time, zero if there is no time limit
+	Deadline time.Time
+
+	// every clue and guess in the order they were made
+	History []Move
+
+	// picks the words opened as a penalty, the global source if nil
+	rng *rand.Rand
+}
+
+// New sets up the game on the board. If the settings leave the first team to chance,
+// it is the team that has more words on the board
+func New(board Board, s Settings) *Game {
+	first := s.FirstTeam
+	if first == Random {
+		first = Blue
+		for _, color := range s.TeamColors() {
+			if board.Count(color) > board.Count(first) {
+				first = color
+			}
+		}
+	}
+	g := &Game{
+		Board:    board,
+		First:    first,
+		Settings: s,
+	}
+	for _, color := range s.TeamColors() {
+		team := &Team{
+			Color:     color,
+			WordsLeft: board.Count(color),
+		}
+		// in duet it is the agents on the side's key card
+		if s.Mode == Duet {
+			team.WordsLeft = board.CountKey(color, Green)
+		}
+		g.Teams = append(g.Teams, team)
+	}
+	return g
+}
+
+// UnmarshalJSON reads the games saved back when there were only blue and red teams
+func (g *Game) UnmarshalJSON(data []byte) error {
+	type game Game
+	v := struct {
+		*game
+		Blue *Team
+		Red  *Team
+	}{game: (*game)(g)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if g.Teams == nil && v.Blue != nil && v.Red != nil {
+		g.Teams = []*Team{v.Blue, v.Red}
+	}
+	return nil
+}
+
+// Team returns the team of the given color or nil if there is no such team
+func (g *Game) Team(color string) *Team {
+	for _, team := range g.Teams {
+		if team.Color == color {
+			return team
+		}
+	}
+	return nil
+}
+
+// Next returns the team that plays after the team of the given color,
+// skipping the eliminated ones. In a game of two it is the other team
+func (g *Game) Next(color string) *Team {
+	i := slices.IndexFunc(g.Teams, func(t *Team) bool { return t.Color == color })
+	if i < 0 {
+		return nil
+	}
+	for range g.Teams {
+		i = (i + 1) % len(g.Teams)
+		if !g.Teams[i].Eliminated {
+			return g.Teams[i]
+		}
+	}
+	return nil
+}
+
+// active returns the teams that haven't been eliminated
+func (g *Game) active() []*Team {
+	var teams []*Team
+	for _, team := range g.Teams {
+		if !team.Eliminated {
+			teams = append(teams, team)
+		}
+	}
+	return teams
+}
+
+// Players returns everyone who has taken a seat
+func (g *Game) Players() []*Player {
+	var players []*Player
+	for _, team := range g.Teams {
+		if team.Spymaster != nil {
+			players = append(players, team.Spymaster)
+		}
+		players = append(players, team.Operatives...)
+	}
+	return players
+}
+
+func (g *Game) Begun() bool {
+	return g.Phase != Lobby
+}
+
+func (g *Game) Ended() bool {
+	return g.Phase == Over
+}
+
+// Seat puts the player to the seat described by their Team and Role.
+// A team has one spymaster, but there may be as many operatives as the settings allow.
+// In duet, each side has a single player who sits as its spymaster
+func (g *Game) Seat(p *Player) error {
+	team := g.Team(p.Team)
+	if team == nil || g.Mode == Duet && p.Role != Spymaster {
+		return ErrInvalidSeat
+	}
+	switch p.Role {
+	case Operative:
+		if team.HasOperative(p) {
+			return ErrSeatTaken
+		}
+		if g.TeamFull(team.Color) {
+			return ErrTeamFull
+		}
+		team.Operatives = append(team.Operatives, p)
+	case Spymaster:
+		if team.Spymaster != nil {
+			return ErrSeatTaken
+		}
+		team.Spymaster = p
+	default:
+		return ErrInvalidSeat
+	}
+	return nil
+}
+
+// TeamFull reports whether the team of the given color can't take any more operatives
+func (g *Game) TeamFull(color string) bool {
+	team := g.Team(color)
+	return team == nil || g.Mode == Duet || g.MaxOperatives > 0 && len(team.Operatives) >= g.MaxOperatives
+}
+
+// Ready reports whether every team has a spymaster and at least one operative,
+// so that the game can be started. Duet only needs a player on each side
+func (g *Game) Ready() bool {
+	for _, team := range g.Teams {
+		if team.Spymaster == nil || g.Mode != Duet && len(team.Operatives) == 0 {
+			return false
+		}
+	}
+	return len(g.Teams) > 0
+}
+
+// Start begins the game with the spymaster of the first team as the first player to act
+func (g *Game) Start() ([]Event, error) {
+	if g.Begun() {
+		return nil, ErrBegun
+	}
+	if !g.Ready() {
+		return nil, ErrNotReady
+	}
+	g.Turn = g.First
+	g.Phase = Giving
+	if g.Mode == Duet {
+		g.TokensLeft = g.Tokens
+	}
+	g.startTimer()
+	return []Event{TurnStarted{Team: g.Turn}}, nil
+}
+
+// acting checks whether it is the player's move in the given phase
+// and returns their team
+func (g *Game) acting(p *Player, role string, phase Phase) (*Team, error) {
+	switch g.Phase {
+	case Lobby:
+		return nil, ErrNotReady
+	case Over:
+		return nil, ErrGameOver
+	}
+	team := g.Team(g.Turn)
+	if g.Phase != phase || p == nil || p.Team != g.Turn || p.Role != role {
+		return nil, ErrNotYourTurn
+	}
+	if role == Spymaster && (team.Spymaster == nil || team.Spymaster.ID != p.ID) {
+		return nil, ErrNotYourTurn
+	}
+	if role == Operative && !team.HasOperative(p) {
+		return nil, ErrNotYourTurn
+	}
+	return team, nil
+}
+
+// GiveClue lets the current spymaster give a clue to their operatives
+func (g *Game) GiveClue(p *Player, word string, number int) ([]Event, error) {
+	team, err := g.acting(p, Spymaster, Giving)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.ValidateClue(team, word, number); err != nil {
+		return nil, err
+	}
+	g.Clue = &Clue{
+		Team:   team.Color,
+		Word:   strings.TrimSpace(word),
+		Number: number,
+	}
+	g.record(Move{
+		Kind:     MoveClue,
+		PlayerID: p.ID,
+		Nickname: p.Nickname,
+		Team:     team.Color,
+		Clue:     g.Clue,
+	})
+	// operatives can make clue.Number + 1 guesses or less, if they choose to end guessing,
+	// in duet they go on for as long as they find agents
+	g.GuessesLeft = g.Clue.Guesses()
+	if g.Mode == Duet {
+		g.GuessesLeft = Unlimited
+	}
+	g.Phase = Guessing
+	g.startTimer()
+	return []Event{ClueGiven{Clue: *g.Clue}}, nil
+}
+
+// Guess opens the cell for any operative of the current team. A wrong color ends the turn,
+// and the assassin or the last word of a team ends the game
+func (g *Game) Guess(p *Player, row, col int) ([]Event, error) {
+	if g.Mode == Duet {
+		return g.guessDuet(p, row, col)
+	}
+	team, err := g.acting(p, Operative, Guessing)
+	if err != nil {
+		return nil, err
+	}
+	cell, err := g.Board.Cell(row, col)
+	if err != nil {
+		return nil, err
+	}
+	if cell.IsOpen {
+		return nil, ErrCellOpen
+	}
+	events, goOn := g.open(team, row, col, Move{
+		Kind:     MoveGuess,
+		PlayerID: p.ID,
+		Nickname: p.Nickname,
+		Team:     team.Color,
+	})
+	if !goOn {
+		return events, nil
+	}
+
+	if g.GuessesLeft == Unlimited {
+		return events, nil
+	}
+	g.GuessesLeft--
+	if g.GuessesLeft == 0 {
+		return g.endTurn(events), nil
+	}
+	return events, nil
+}
+
+// open opens the cell on behalf of the team, records the move and evaluates the result.
+// It reports whether the team can go on guessing
+func (g *Game) open(team *Team, row, col int, move Move) ([]Event, bool) {
+	cell := &g.Board[row][col]
+	cell.IsOpen = true
+	cell.Proposals = nil
+
+	move.Row, move.Col = row, col
+	move.Word, move.Color = cell.Word, cell.Color
+	g.record(move)
+	events := []Event{CellOpened{Row: row, Col: col, Cell: *cell}}
+
+	// evaluating the move
+	owner := g.Team(cell.Color)
+	switch {
+	case owner == team:
+		team.WordsLeft--
+		if team.WordsLeft == 0 {
+			return g.finish(events, team.Color), false
+		}
+		return events, true
+	case owner != nil:
+		// the word goes to the team it belongs to, even if it is out of the game
+		owner.WordsLeft--
+		if owner.WordsLeft == 0 && !owner.Eliminated {
+			return g.finish(events, owner.Color), false
+		}
+		return g.endTurn(events), false
+	case cell.Color == Black:
+		return g.eliminate(events, team), false
+	default:
+		return g.endTurn(events), false
+	}
+}
+
+// eliminate takes the team that has hit the assassin out of the game. With two teams
+// the other one wins right away, with more the game goes on until one is left
+func (g *Game) eliminate(events []Event, team *Team) []Event {
+	if len(g.active()) <= 2 {
+		return g.finish(events, g.Next(team.Color).Color)
+	}
+	team.Eliminated = true
+	events = append(events, TeamEliminated{Team: team.Color})
+	if active := g.active(); len(active) == 1 {
+		return g.finish(events, active[0].Color)
+	}
+	return g.endTurn(events)
+}
+
+// Propose marks the cell with the operative's vote, or takes the vote back if it is already there.
+// The cell opens as soon as enough operatives of the team agree on it
+func (g *Game) Propose(p *Player, row, col int) ([]Event, error) {
+	// there is no one to vote with in duet
+	if g.Mode == Duet {
+		return g.guessDuet(p, row, col)
+	}
+	team, err := g.acting(p, Operative, Guessing)
+	if err != nil {
+		return nil, err
+	}
+	cell, err := g.Board.Cell(row, col)
+	if err != nil {
+		return nil, err
+	}
+	if cell.IsOpen {
+		return nil, ErrCellOpen
+	}
+
+	if i := slices.Index(cell.Proposals, p.ID); i >= 0 {
+		cell.Proposals = slices.Delete(cell.Proposals, i, i+1)
+	} else {
+		cell.Proposals = append(cell.Proposals, p.ID)
+	}
+	if len(cell.Proposals) >= g.quorum(team) {
+		return g.Guess(p, row, col)
+	}
+	return []Event{CellProposed{Row: row, Col: col, Cell: *cell}}, nil
+}
+
+// quorum returns the number of votes needed to open a cell for the team
+func (g *Game) quorum(team *Team) int {
+	if g.Quorum > 0 {
+		return min(g.Quorum, len(team.Operatives))
+	}
+	return len(team.Operatives)/2 + 1
+}
+
+// EndGuessing passes the turn before the operatives run out of guesses
+func (g *Game) EndGuessing(p *Player) ([]Event, error) {
+	if g.Mode == Duet {
+		return g.endGuessingDuet(p)
+	}
+	team, err := g.acting(p, Operative, Guessing)
+	if err != nil {
+		return nil, err
+	}
+	g.record(Move{
+		Kind:     MoveEndGuessing,
+		PlayerID: p.ID,
+		Nickname: p.Nickname,
+		Team:     team.Color,
+	})
+	return g.endTurn(nil), nil
+}
+
+func (g *Game) endTurn(events []Event) []Event {
+	events = append(events, TurnEnded{Team: g.Turn})
+	g.Board.clearProposals()
+	next := g.Next(g.Turn).Color
+	if g.Mode == Duet {
+		var ok bool
+		if next, ok = g.nextDuet(); !ok {
+			return g.finish(events, Black)
+		}
+	}
+	g.Turn = next
+	g.Clue = nil
+	g.GuessesLeft = 0
+	g.Phase = Giving
+	g.startTimer()
+	return append(events, TurnStarted{Team: g.Turn})
+}
+
+func (g *Game) finish(events []Event, winner string) []Event {
+	g.Winner = winner
+	g.Board.clearProposals()
+	g.Clue = nil
+	g.GuessesLeft = 0
+	g.Phase = Over
+	g.startTimer()
+	return append(events, GameOver{Winner: winner})
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+// testBoard is a 3x3 board with three blue words, two red ones, three bystanders and the assassin:
+//
+//	blue  blue  red
+//	red   white black
+//	blue  white white
+func testBoard() Board {
+	colors := [][]string{
+		{Blue, Blue, Red},
+		{Red, White, Black},
+		{Blue, White, White},
+	}
+	board := make(Board, len(colors))
+	for i, row := range colors {
+		board[i] = make([]Cell, len(row))
+		for j, color := range row {
+			board[i][j] = Cell{Word: color + string(rune('0'+i*3+j)), Color: color}
+		}
+	}
+	return board
+}
+
+// testGame starts a game on the test board with blue going first and returns it with the blue operative
+func testGame(t *testing.T) (*Game, *Player) {
+	t.Helper()
+	s := DefaultSettings()
+	s.Rows, s.Cols = 3, 3
+	s.FirstTeam = Blue
+	s.KeyCard = KeyCard{First: 3, Second: 2, Bystanders: 3, Assassins: 1}
+	g := New(testBoard(), s)
+
+	operative := &Player{ID: "bo", Nickname: "bo", Team: Blue, Role: Operative}
+	for _, p := range []*Player{
+		{ID: "bs", Nickname: "bs", Team: Blue, Role: Spymaster},
+		operative,
+		{ID: "rs", Nickname: "rs", Team: Red, Role: Spymaster},
+		{ID: "ro", Nickname: "ro", Team: Red, Role: Operative},
+	} {
+		if err := g.Seat(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return g, operative
+}
+
+func TestGuess(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  int
+		guesses [][2]int
+		// the state of the game after the guesses
+		turn        string
+		phase       Phase
+		winner      string
+		guessesLeft int
+		blueLeft    int
+		redLeft     int
+	}{
+		{
+			name:    "own word",
+			number:  1,
+			guesses: [][2]int{{0, 0}},
+			turn:    Blue, phase: Guessing, guessesLeft: 1, blueLeft: 2, redLeft: 2,
+		},
+		{
+			name:    "other team's word",
+			number:  1,
+			guesses: [][2]int{{0, 2}},
+			turn:    Red, phase: Giving, blueLeft: 3, redLeft: 1,
+		},
+		{
+			name:    "bystander",
+			number:  1,
+			guesses: [][2]int{{1, 1}},
+			turn:    Red, phase: Giving, blueLeft: 3, redLeft: 2,
+		},
+		{
+			name:    "assassin",
+			number:  1,
+			guesses: [][2]int{{1, 2}},
+			turn:    Blue, phase: Over, winner: Red, blueLeft: 3, redLeft: 2,
+		},
+		{
+			name:    "last word wins",
+			number:  3,
+			guesses: [][2]int{{0, 0}, {0, 1}, {2, 0}},
+			turn:    Blue, phase: Over, winner: Blue, blueLeft: 0, redLeft: 2,
+		},
+		{
+			name:    "guesses count down",
+			number:  2,
+			guesses: [][2]int{{0, 0}, {0, 1}},
+			turn:    Blue, phase: Guessing, guessesLeft: 1, blueLeft: 1, redLeft: 2,
+		},
+		{
+			name:    "out of guesses",
+			number:  1,
+			guesses: [][2]int{{0, 0}, {0, 1}},
+			turn:    Red, phase: Giving, blueLeft: 1, redLeft: 2,
+		},
+		{
+			name:    "unlimited clue",
+			number:  Unlimited,
+			guesses: [][2]int{{0, 0}, {0, 1}},
+			turn:    Blue, phase: Guessing, guessesLeft: Unlimited, blueLeft: 1, redLeft: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, operative := testGame(t)
+			if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", tt.number); err != nil {
+				t.Fatal(err)
+			}
+			for _, guess := range tt.guesses {
+				if _, err := g.Guess(operative, guess[0], guess[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if g.Turn != tt.turn || g.Phase != tt.phase || g.Winner != tt.winner {
+				t.Errorf("turn %s, phase %d, winner %q, want %s, %d, %q", g.Turn, g.Phase, g.Winner, tt.turn, tt.phase, tt.winner)
+			}
+			if g.GuessesLeft != tt.guessesLeft {
+				t.Errorf("%d guesses left, want %d", g.GuessesLeft, tt.guessesLeft)
+			}
+			if blue, red := g.Team(Blue).WordsLeft, g.Team(Red).WordsLeft; blue != tt.blueLeft || red != tt.redLeft {
+				t.Errorf("words left: blue %d, red %d, want %d, %d", blue, red, tt.blueLeft, tt.redLeft)
+			}
+		})
+	}
+}
+
+func TestOpponentsLastWordWins(t *testing.T) {
+	g, operative := testGame(t)
+	// red is one word away from winning
+	g.Team(Red).WordsLeft = 1
+	if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Guess(operative, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Ended() || g.Winner != Red {
+		t.Errorf("phase %d, winner %q, want the game won by red", g.Phase, g.Winner)
+	}
+}
+
+func TestGuessOutOfTurn(t *testing.T) {
+	g, operative := testGame(t)
+	// the spymaster hasn't given a clue yet
+	if _, err := g.Guess(operative, 0, 0); err != ErrNotYourTurn {
+		t.Errorf("got %v, want %v", err, ErrNotYourTurn)
+	}
+	if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Guess(g.Team(Red).Operatives[0], 0, 0); err != ErrNotYourTurn {
+		t.Errorf("got %v, want %v", err, ErrNotYourTurn)
+	}
+	if _, err := g.Guess(operative, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Guess(operative, 0, 0); err != ErrCellOpen {
+		t.Errorf("got %v, want %v", err, ErrCellOpen)
+	}
+}
+
+func TestEndGuessing(t *testing.T) {
+	g, operative := testGame(t)
+	if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Guess(operative, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.EndGuessing(operative); err != nil {
+		t.Fatal(err)
+	}
+	if g.Turn != Red || g.Phase != Giving || g.GuessesLeft != 0 || g.Clue != nil {
+		t.Errorf("turn %s, phase %d, %d guesses left, clue %v, want red to give a clue", g.Turn, g.Phase, g.GuessesLeft, g.Clue)
+	}
+}
+
+// testVoting starts a game on the test board where blue has three operatives who vote on the cells
+func testVoting(t *testing.T, quorum int) (*Game, []*Player) {
+	t.Helper()
+	g, operative := testGame(t)
+	g.Quorum = quorum
+	operatives := []*Player{operative}
+	for _, id := range []string{"bo2", "bo3"} {
+		p := &Player{ID: id, Nickname: id, Team: Blue, Role: Operative}
+		if err := g.Seat(p); err != nil {
+			t.Fatal(err)
+		}
+		operatives = append(operatives, p)
+	}
+	if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 2); err != nil {
+		t.Fatal(err)
+	}
+	return g, operatives
+}
+
+// vote is a click of the operative, by their index, on the cell
+type vote struct{ operative, row, col int }
+
+func TestPropose(t *testing.T) {
+	tests := []struct {
+		name   string
+		quorum int
+		votes  []vote
+		// whether blue's word at 0,0 is open after the votes, and the votes left on it
+		open      bool
+		proposals []string
+	}{
+		{
+			name:      "one vote of three",
+			votes:     []vote{{0, 0, 0}},
+			proposals: []string{"bo"},
+		},
+		{
+			name:  "majority",
+			votes: []vote{{0, 0, 0}, {1, 0, 0}},
+			open:  true,
+		},
+		{
+			name:   "quorum of one",
+			quorum: 1,
+			votes:  []vote{{2, 0, 0}},
+			open:   true,
+		},
+		{
+			name:      "quorum of everyone",
+			quorum:    3,
+			votes:     []vote{{0, 0, 0}, {1, 0, 0}},
+			proposals: []string{"bo", "bo2"},
+		},
+		{
+			name:   "quorum above the operatives",
+			quorum: 5,
+			votes:  []vote{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}},
+			open:   true,
+		},
+		{
+			name: "vote taken back",
+			// the first operative changes their mind and votes for the other word instead
+			votes:     []vote{{0, 0, 0}, {0, 0, 0}, {0, 0, 1}, {1, 0, 0}},
+			proposals: []string{"bo2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, operatives := testVoting(t, tt.quorum)
+			for _, v := range tt.votes {
+				if _, err := g.Propose(operatives[v.operative], v.row, v.col); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cell := g.Board[0][0]
+			if cell.IsOpen != tt.open {
+				t.Errorf("open %v, want %v", cell.IsOpen, tt.open)
+			}
+			if !slices.Equal(cell.Proposals, tt.proposals) {
+				t.Errorf("proposals %v, want %v", cell.Proposals, tt.proposals)
+			}
+		})
+	}
+}
+
+func TestProposalsClearedOnTurnEnd(t *testing.T) {
+	g, operatives := testVoting(t, 0)
+	if _, err := g.Propose(operatives[0], 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Propose(operatives[1], 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	events, err := g.EndGuessing(operatives[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(events, func(e Event) bool { _, ok := e.(TurnEnded); return ok }) {
+		t.Errorf("events %v, want the turn to end", events)
+	}
+	for i := range g.Board {
+		for j, cell := range g.Board[i] {
+			if len(cell.Proposals) > 0 {
+				t.Errorf("cell %d,%d still has the votes %v", i, j, cell.Proposals)
+			}
+		}
+	}
+
+	// the votes of a turn don't carry over to the next clue of the team
+	g.GiveClue(g.Team(Red).Spymaster, "clue", 1)
+	g.EndGuessing(g.Team(Red).Operatives[0])
+	g.GiveClue(g.Team(Blue).Spymaster, "clue", 1)
+	if _, err := g.Propose(operatives[1], 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cell := g.Board[0][0]; cell.IsOpen || !slices.Equal(cell.Proposals, []string{"bo2"}) {
+		t.Errorf("open %v, proposals %v, want only the new vote", cell.IsOpen, cell.Proposals)
+	}
+}
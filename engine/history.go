@@ -0,0 +1,71 @@
+package engine
+
+import "time"
+
+// kinds of moves in the history
+const (
+	MoveClue        = "clue"
+	MoveGuess       = "guess"
+	MoveEndGuessing = "end-guessing"
+	MoveTimeout     = "timeout"
+)
+
+// Move is a single entry of the game's history
+type Move struct {
+	Time     time.Time
+	Kind     string
+	PlayerID string
+	Nickname string
+	Team     string
+
+	// set for clues
+	Clue *Clue `json:",omitempty"`
+
+	// set for guesses and for timeouts that opened a word as a penalty,
+	// Color is the color the cell turned out to be
+	Row   int
+	Col   int
+	Word  string `json:",omitempty"`
+	Color string `json:",omitempty"`
+}
+
+func (g *Game) record(m Move) {
+	m.Time = time.Now()
+	g.History = append(g.History, m)
+}
+
+// BoardAt returns the board as it was after the first n moves of the game
+func (g *Game) BoardAt(n int) Board {
+	n = max(0, min(n, len(g.History)))
+
+	board := g.Board.copy()
+	for i := range board {
+		for j := range board[i] {
+			board[i][j].IsOpen = false
+			board[i][j].Proposals = nil
+			board[i][j].Bystanders = nil
+		}
+	}
+	for _, m := range g.History[:n] {
+		if m.Kind == MoveGuess || m.Kind == MoveTimeout && m.Word != "" {
+			g.replayGuess(board, m)
+		}
+	}
+	return board
+}
+
+// replayGuess opens the guessed cell. In duet only the agents are opened,
+// a bystander is marked for the key card of the side that has given the clue
+func (g *Game) replayGuess(board Board, m Move) {
+	cell := &board[m.Row][m.Col]
+	if g.Mode != Duet {
+		cell.IsOpen = true
+		return
+	}
+	switch m.Color {
+	case Green:
+		cell.IsOpen = true
+	case White:
+		cell.Bystanders = append(cell.Bystanders, g.Next(m.Team).Color)
+	}
+}
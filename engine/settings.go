@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+// Random lets the engine choose the team that goes first
+const Random = "random"
+
+// who can see what without taking a seat
+const (
+	SpectatorsOperative = ""          // spectators see the board like operatives do
+	SpectatorsSpymaster = "spymaster" // spectators see the key card, good for commentators
+	SpectatorsNone      = "none"      // spectators don't see the board until the game is over
+)
+
+var ErrSettings = errors.New("invalid settings")
+
+// KeyCard is how many cells of each kind are dealt on the board
+type KeyCard struct {
+	First      int // words of the team going first
+	Second     int // words of the team going next
+	Third      int `json:",omitempty"` // words of the team going last in a game of three
+	Bystanders int
+	Assassins  int
+}
+
+// KeyCardFor keeps the proportions of the classic key card on a board of any size:
+// 9/8/7/1 for two teams, that is about a third of the cells for each team, and 7/6/6/5/1
+// for three teams. There is one assassin, bystanders take the rest of the cells,
+// and the team going first has an extra word to make up for it
+func KeyCardFor(rows, cols, teams int) KeyCard {
+	cells := rows * cols
+	if teams == 3 {
+		words := (cells*6 + 12) / 25 // 6 of 25, rounded
+		return KeyCard{
+			First:      words + 1,
+			Second:     words,
+			Third:      words,
+			Bystanders: cells - 3*words - 2,
+			Assassins:  1,
+		}
+	}
+	second := (cells*8 + 12) / 25 // 8 of 25, rounded
+	return KeyCard{
+		First:      second + 1,
+		Second:     second,
+		Bystanders: cells - 2*second - 2,
+		Assassins:  1,
+	}
+}
+
+func (k KeyCard) Total() int {
+	return k.First + k.Second + k.Third + k.Bystanders + k.Assassins
+}
+
+// Settings are chosen when the game is created and don't change after that
+type Settings struct {
+	Mode      string // Classic or Duet
+	NumTeams  int    // 2 or 3, zero is 2 as well
+	FirstTeam string // Blue, Red, Green or Random
+	Rows      int
+	Cols      int
+	KeyCard   KeyCard
+
+	// Quorum is how many operatives have to agree on a cell before it opens,
+	// zero means a simple majority of the team's operatives
+	Quorum int
+
+	// MaxOperatives limits the operatives of each team, zero means no limit
+	MaxOperatives int
+
+	Spectators string
+	ClueRules  ClueRules
+	Timers     Timers
+
+	// Tokens is how many turns the players have in duet
+	Tokens int `json:",omitempty"`
+
+	// Seed makes the board reproducible: the same words and settings with the same seed
+	// make the same board, so that different groups can play it
+	Seed int64
+}
+
+// DefaultSettings are the settings of the classic game
+func DefaultSettings() Settings {
+	return Settings{
+		NumTeams:  2,
+		FirstTeam: Random,
+		Rows:      Size,
+		Cols:      Size,
+		KeyCard:   KeyCardFor(Size, Size, 2),
+	}
+}
+
+// TeamColors returns the colors of the teams in the order they take turns,
+// a third team plays green
+func (s Settings) TeamColors() []string {
+	if s.NumTeams == 3 {
+		return []string{Blue, Red, Green}
+	}
+	return []string{Blue, Red}
+}
+
+// order returns the colors of the teams in the order they take turns starting from the first one
+func (s Settings) order(first string) []string {
+	colors := s.TeamColors()
+	i := max(0, slices.Index(colors, first))
+	return append(colors[i:], colors[:i]...)
+}
+
+// Cells returns the number of cells on the board, that is how many words the game needs
+func (s Settings) Cells() int {
+	return s.Rows * s.Cols
+}
+
+// Validate checks that a game can be played with these settings
+func (s Settings) Validate() error {
+	if s.NumTeams != 2 && s.NumTeams != 3 {
+		return fmt.Errorf("%w: there can be two or three teams", ErrSettings)
+	}
+	if s.FirstTeam != Random && !slices.Contains(s.TeamColors(), s.FirstTeam) {
+		return fmt.Errorf("%w: unknown team %q", ErrSettings, s.FirstTeam)
+	}
+	switch s.Mode {
+	case Classic:
+	case Duet:
+		if s.NumTeams != 2 {
+			return fmt.Errorf("%w: duet is played by two sides", ErrSettings)
+		}
+		if s.Rows != DuetSize || s.Cols != DuetSize {
+			return fmt.Errorf("%w: duet is played on a %dx%d board", ErrSettings, DuetSize, DuetSize)
+		}
+		if s.Tokens < 1 {
+			return fmt.Errorf("%w: duet needs at least one turn", ErrSettings)
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrSettings, s.Mode)
+	}
+	if s.Rows < MinSize || s.Rows > MaxSize || s.Cols < MinSize || s.Cols > MaxSize {
+		return fmt.Errorf("%w: the board can be from %dx%d to %dx%d", ErrSettings, MinSize, MinSize, MaxSize, MaxSize)
+	}
+	k := s.KeyCard
+	if k.First < 1 || k.Second < 1 || s.NumTeams == 3 && k.Third < 1 {
+		return fmt.Errorf("%w: each team needs at least one word", ErrSettings)
+	}
+	if s.NumTeams == 2 && k.Third != 0 {
+		return fmt.Errorf("%w: there is no third team", ErrSettings)
+	}
+	if k.Bystanders < 0 || k.Assassins < 0 {
+		return fmt.Errorf("%w: the key card doesn't fit on the board", ErrSettings)
+	}
+	if k.Total() != s.Cells() {
+		return fmt.Errorf("%w: the key card has %d cells, but the board has %d", ErrSettings, k.Total(), s.Cells())
+	}
+	if s.Quorum < 0 || s.MaxOperatives < 0 {
+		return fmt.Errorf("%w: negative number of operatives", ErrSettings)
+	}
+	switch s.Spectators {
+	case SpectatorsOperative, SpectatorsSpymaster, SpectatorsNone:
+	default:
+		return fmt.Errorf("%w: unknown spectator permissions %q", ErrSettings, s.Spectators)
+	}
+	if s.Timers.Clue < 0 || s.Timers.Guess < 0 {
+		return fmt.Errorf("%w: negative time limit", ErrSettings)
+	}
+	switch s.Timers.Penalty {
+	case PenaltyPass, PenaltyReveal:
+	default:
+		return fmt.Errorf("%w: unknown penalty %q", ErrSettings, s.Timers.Penalty)
+	}
+	return nil
+}
+
+// Deal picks the team going first and deals the key card over the words,
+// so that the team gets the larger share of them. There must be s.Cells() words.
+// Both are decided by s.Seed
+func Deal(words []string, s Settings) *Game {
+	rng := rand.New(rand.NewSource(s.Seed))
+	first := s.FirstTeam
+	if first == Random {
+		colors := s.TeamColors()
+		first = colors[rng.Intn(len(colors))]
+	}
+	var board Board
+	if s.Mode == Duet {
+		board = NewDuetBoard(rng, words)
+	} else {
+		board = NewBoard(rng, s.Rows, s.Cols, words, s.KeyCard, s.order(first))
+	}
+	g := New(board, s)
+	g.First = first
+	return g
+}
+
+// View returns the role whose board the player sees, p is nil for spectators.
+// An empty role means the spectators don't see the board at all.
+// Everyone sees the key card once the game is over
+func (g *Game) View(p *Player) string {
+	switch {
+	case g.Ended():
+		return Spymaster
+	case p != nil:
+		return p.Role
+	case g.Spectators == SpectatorsSpymaster:
+		return Spymaster
+	case g.Spectators == SpectatorsNone:
+		return ""
+	}
+	return Operative
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testWords(n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprint("word", i)
+	}
+	return words
+}
+
+func TestDealSeed(t *testing.T) {
+	for _, teams := range []int{2, 3} {
+		s := DefaultSettings()
+		s.NumTeams = teams
+		s.KeyCard = KeyCardFor(s.Rows, s.Cols, teams)
+		s.Seed = 42
+		words := testWords(s.Cells())
+
+		g := Deal(words, s)
+		if again := Deal(words, s); g.First != again.First || !reflect.DeepEqual(g.Board, again.Board) {
+			t.Errorf("%d teams: the same seed dealt different boards", teams)
+		}
+		if got := g.Board.Count(g.First); got != s.KeyCard.First {
+			t.Errorf("%d teams: the first team %s has %d words, want %d", teams, g.First, got, s.KeyCard.First)
+		}
+
+		s.Seed = 43
+		if other := Deal(words, s); reflect.DeepEqual(g.Board, other.Board) {
+			t.Errorf("%d teams: different seeds dealt the same board", teams)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"math/rand"
+	"time"
+)
+
+// what happens to the team that runs out of time
+const (
+	PenaltyPass   = ""       // the turn passes to the other team
+	PenaltyReveal = "reveal" // the turn passes and one of the other team's words is opened for them
+)
+
+// Timers are the time limits of the game, zero means no limit
+type Timers struct {
+	Clue    time.Duration // for the spymaster to give a clue
+	Guess   time.Duration // for the operatives to guess
+	Penalty string
+}
+
+// startTimer sets the deadline for the current phase
+func (g *Game) startTimer() {
+	var limit time.Duration
+	switch g.Phase {
+	case Giving:
+		limit = g.Timers.Clue
+	case Guessing:
+		limit = g.Timers.Guess
+	}
+	if limit <= 0 {
+		g.Deadline = time.Time{}
+		return
+	}
+	g.Deadline = time.Now().Add(limit)
+}
+
+// TimeLeft returns how much time the current team has left, zero if there is no limit
+func (g *Game) TimeLeft(now time.Time) time.Duration {
+	if g.Deadline.IsZero() {
+		return 0
+	}
+	return max(0, g.Deadline.Sub(now))
+}
+
+// Timeout ends the turn of the team if it has run out of time by now,
+// otherwise it does nothing and returns no events
+func (g *Game) Timeout(now time.Time) []Event {
+	if g.Deadline.IsZero() || now.Before(g.Deadline) {
+		return nil
+	}
+	if g.Phase != Giving && g.Phase != Guessing {
+		return nil
+	}
+	team := g.Team(g.Turn)
+	events := []Event{TimedOut{Team: team.Color}}
+	move := Move{
+		Kind: MoveTimeout,
+		Team: team.Color,
+	}
+
+	if g.Timers.Penalty == PenaltyReveal {
+		if row, col, ok := g.randomClosed(g.Next(team.Color).Color); ok {
+			opened, goOn := g.open(team, row, col, move)
+			events = append(events, opened...)
+			if goOn {
+				events = g.endTurn(events)
+			}
+			return events
+		}
+	}
+	g.record(move)
+	return g.endTurn(events)
+}
+
+// randomClosed picks a random closed cell of the given color
+func (g *Game) randomClosed(color string) (int, int, bool) {
+	var cells [][2]int
+	for i := range g.Board {
+		for j, cell := range g.Board[i] {
+			if cell.Color == color && !cell.IsOpen {
+				cells = append(cells, [2]int{i, j})
+			}
+		}
+	}
+	if len(cells) == 0 {
+		return 0, 0, false
+	}
+	intn := rand.Intn
+	if g.rng != nil {
+		intn = g.rng.Intn
+	}
+	c := cells[intn(len(cells))]
+	return c[0], c[1], true
+}
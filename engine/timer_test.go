@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testTimedGame starts a game on the test board where each phase has a minute,
+// the penalty words are picked by a seeded source
+func testTimedGame(t *testing.T, penalty string) *Game {
+	t.Helper()
+	g, _ := testGame(t)
+	g.Timers = Timers{Clue: time.Minute, Guess: time.Minute, Penalty: penalty}
+	g.rng = rand.New(rand.NewSource(1))
+	g.startTimer()
+	return g
+}
+
+// openCells returns how many cells of the board are open
+func openCells(b Board) int {
+	var n int
+	for i := range b {
+		for _, cell := range b[i] {
+			if cell.IsOpen {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestTimeoutNotYet(t *testing.T) {
+	g := testTimedGame(t, PenaltyPass)
+	if events := g.Timeout(time.Now()); events != nil {
+		t.Errorf("events %v, want none before the deadline", events)
+	}
+	if g.Turn != Blue || g.Phase != Giving {
+		t.Errorf("turn %s, phase %d, want blue to give a clue", g.Turn, g.Phase)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		penalty string
+		// blue has given a clue and is guessing when the time runs out
+		guessing bool
+		// red has found all its words but the one at 0,2
+		redLast bool
+		// the state of the game after the timeout
+		turn    string
+		phase   Phase
+		winner  string
+		redLeft int
+		opened  int
+	}{
+		{
+			name:    "no penalty",
+			penalty: PenaltyPass,
+			turn:    Red, phase: Giving, redLeft: 2, opened: 0,
+		},
+		{
+			name:     "no penalty while guessing",
+			penalty:  PenaltyPass,
+			guessing: true,
+			turn:     Red, phase: Giving, redLeft: 2, opened: 0,
+		},
+		{
+			name:    "penalty",
+			penalty: PenaltyReveal,
+			turn:    Red, phase: Giving, redLeft: 1, opened: 1,
+		},
+		{
+			name:     "penalty while guessing",
+			penalty:  PenaltyReveal,
+			guessing: true,
+			turn:     Red, phase: Giving, redLeft: 1, opened: 1,
+		},
+		{
+			name:    "penalty opens the last word",
+			penalty: PenaltyReveal,
+			redLast: true,
+			turn:    Blue, phase: Over, winner: Red, redLeft: 0, opened: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testTimedGame(t, tt.penalty)
+			if tt.redLast {
+				g.Board[1][0].IsOpen = true
+				g.Team(Red).WordsLeft = 1
+			}
+			if tt.guessing {
+				if _, err := g.GiveClue(g.Team(Blue).Spymaster, "clue", 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events := g.Timeout(g.Deadline)
+			if len(events) == 0 {
+				t.Fatal("no events at the deadline")
+			}
+			if e, ok := events[0].(TimedOut); !ok || e.Team != Blue {
+				t.Errorf("first event %v, want blue to time out", events[0])
+			}
+			if g.Turn != tt.turn || g.Phase != tt.phase || g.Winner != tt.winner {
+				t.Errorf("turn %s, phase %d, winner %q, want %s, %d, %q", g.Turn, g.Phase, g.Winner, tt.turn, tt.phase, tt.winner)
+			}
+			if left := g.Team(Red).WordsLeft; left != tt.redLeft {
+				t.Errorf("red has %d words left, want %d", left, tt.redLeft)
+			}
+			if n := openCells(g.Board); n != tt.opened {
+				t.Errorf("%d cells open, want %d", n, tt.opened)
+			}
+
+			move := g.History[len(g.History)-1]
+			if move.Kind != MoveTimeout || move.Team != Blue {
+				t.Errorf("last move %s of %s, want a timeout of blue", move.Kind, move.Team)
+			}
+			// the penalty word is recorded along with the timeout
+			if want := tt.penalty == PenaltyReveal; (move.Word != "") != want || want && move.Color != Red {
+				t.Errorf("timeout opened %q of color %q", move.Word, move.Color)
+			}
+		})
+	}
+}
+
+func TestTimeoutPenaltySeed(t *testing.T) {
+	// the same source picks the same word
+	var words []string
+	for range 2 {
+		g := testTimedGame(t, PenaltyReveal)
+		g.Timeout(g.Deadline)
+		words = append(words, g.History[len(g.History)-1].Word)
+	}
+	if words[0] != words[1] {
+		t.Errorf("the same seed opened %q and %q", words[0], words[1])
+	}
+}
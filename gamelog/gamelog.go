@@ -0,0 +1,330 @@
+// Package gamelog reads and writes finished games as self-contained JSON files.
+//
+// A log looks like this:
+//
+//	{
+//	  "format": "codenames-log",
+//	  "version": 6,
+//	  "id": "1f0c…",
+//	  "exported": "2024-09-12T18:30:00Z",
+//	  "first": "blue",
+//	  "winner": "blue",
+//	  "board": [
+//	    [{"word": "АГЕНТ", "color": "blue"}, {"word": "АКТ", "color": "white"}, …],
+//	    …
+//	  ],
+//	  "moves": [
+//	    {"time": "…", "kind": "clue", "team": "blue", "player": "nick", "clue": {"word": "spy", "number": 2}},
+//	    {"time": "…", "kind": "guess", "team": "blue", "player": "nick", "row": 0, "col": 0, "word": "АГЕНТ", "color": "blue"},
+//	    {"time": "…", "kind": "end-guessing", "team": "blue", "player": "nick"},
+//	    {"time": "…", "kind": "timeout", "team": "red", "player": ""}
+//	  ]
+//	}
+//
+// The board is a list of rows of the same length, 5x5 in the classic game, but anywhere
+// from 3 to 8 cells each way otherwise. Every cell has the word and its color on the key card:
+// "blue", "red", "white" for bystanders or "black" for the assassin.
+// The moves go in the order they were made, a guess points at the cell by its row and column
+// counting from zero. The number of an unlimited clue is -1. Player IDs are never written,
+// only nicknames.
+//
+// The first team is the one that has given the first clue, it usually has a word more to guess.
+// A game of three teams has "teams": 3, and the third team plays "green". A team that guesses
+// the assassin while the other two are still playing is out of the game.
+//
+// In a game of pictures every cell has "image", the path of its picture inside the pictures
+// directory, like "animals/cat.svg", and the word of the cell is the name of the picture.
+// A path that goes anywhere but right into a picture set makes the log invalid.
+//
+// A duet game has "mode": "duet". Its sides are "blue" and "red", and every cell has
+// the colors on the key cards of both sides: "keys": {"blue": "green", "red": "white"},
+// where "green" is an agent. The color of the cell is "green" if it is an agent on either card,
+// otherwise "black" if it is the assassin on either card, otherwise "white". The color of a guess
+// is the one on the key card of the side that has given the clue, and only agents get opened.
+// The winner is "green" if the players have found all the agents and "black" if they haven't.
+//
+// A timeout means the team has run out of time. When the penalty of the game opens one
+// of the other team's words, the timeout has the word, its color, row and column like a guess.
+//
+// The version is bumped whenever the format changes in a way older readers can't handle,
+// and Read keeps understanding every version it has ever written:
+//
+//   - 1 is the original format
+//   - 2 adds timeouts, and the word and color of every opened cell to the moves
+//   - 3 adds the first team, older logs get it from the first move
+//   - 4 adds duet games
+//   - 5 adds games of three teams
+//   - 6 adds pictures
+package gamelog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+const (
+	Format  = "codenames-log"
+	Version = 6
+)
+
+var (
+	ErrFormat  = errors.New("gamelog: not a codenames log")
+	ErrVersion = errors.New("gamelog: unsupported version")
+	ErrInvalid = errors.New("gamelog: invalid log")
+)
+
+type Log struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	ID       string    `json:"id"`
+	Exported time.Time `json:"exported"`
+	Mode     string    `json:"mode,omitempty"`
+	Teams    int       `json:"teams,omitempty"`
+	First    string    `json:"first"`
+	Winner   string    `json:"winner,omitempty"`
+	Board    [][]Cell  `json:"board"`
+	Moves    []Move    `json:"moves"`
+}
+
+type Cell struct {
+	Word  string            `json:"word"`
+	Color string            `json:"color"`
+	Keys  map[string]string `json:"keys,omitempty"`
+	Image string            `json:"image,omitempty"`
+}
+
+type Clue struct {
+	Word   string `json:"word"`
+	Number int    `json:"number"`
+}
+
+type Move struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Team   string    `json:"team"`
+	Player string    `json:"player"`
+	Clue   *Clue     `json:"clue,omitempty"`
+	Row    int       `json:"row"`
+	Col    int       `json:"col"`
+	Word   string    `json:"word,omitempty"`
+	Color  string    `json:"color,omitempty"`
+}
+
+// Export turns the game into a log
+func Export(id string, g *engine.Game) Log {
+	l := Log{
+		Format:   Format,
+		Version:  Version,
+		ID:       id,
+		Exported: time.Now().UTC(),
+		Mode:     g.Mode,
+		Teams:    len(g.Teams),
+		First:    g.First,
+		Winner:   g.Winner,
+	}
+	for _, row := range g.Board {
+		var cells []Cell
+		for _, cell := range row {
+			cells = append(cells, Cell{Word: cell.Word, Color: cell.Color, Keys: cell.Keys, Image: cell.Image})
+		}
+		l.Board = append(l.Board, cells)
+	}
+	for _, m := range g.History {
+		move := Move{
+			Time:   m.Time,
+			Kind:   m.Kind,
+			Team:   m.Team,
+			Player: m.Nickname,
+			Row:    m.Row,
+			Col:    m.Col,
+			Word:   m.Word,
+			Color:  m.Color,
+		}
+		if m.Clue != nil {
+			move.Clue = &Clue{Word: m.Clue.Word, Number: m.Clue.Number}
+		}
+		l.Moves = append(l.Moves, move)
+	}
+	return l
+}
+
+func Write(w io.Writer, l Log) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// Read decodes the log of any known version
+func Read(r io.Reader) (Log, error) {
+	var l Log
+	if err := json.NewDecoder(r).Decode(&l); err != nil {
+		return l, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if l.Format != Format {
+		return l, ErrFormat
+	}
+	switch l.Version {
+	case 1, 2:
+		// the team that has moved first is the one that went first
+		if len(l.Moves) > 0 {
+			l.First = l.Moves[0].Team
+		}
+		return l, nil
+	case 3, 4, 5, 6:
+		return l, nil
+	default:
+		return l, fmt.Errorf("%w %d", ErrVersion, l.Version)
+	}
+}
+
+// Game recreates the finished game from the log, so that it can be replayed
+func (l Log) Game() (*engine.Game, error) {
+	rows := len(l.Board)
+	if rows < engine.MinSize || rows > engine.MaxSize {
+		return nil, fmt.Errorf("%w: board must have from %d to %d rows", ErrInvalid, engine.MinSize, engine.MaxSize)
+	}
+	cols := len(l.Board[0])
+	if cols < engine.MinSize || cols > engine.MaxSize {
+		return nil, fmt.Errorf("%w: board must have from %d to %d columns", ErrInvalid, engine.MinSize, engine.MaxSize)
+	}
+	settings := engine.DefaultSettings()
+	settings.Mode = l.Mode
+	switch l.Teams {
+	case 0, 2:
+	case 3:
+		settings.NumTeams = 3
+	default:
+		return nil, fmt.Errorf("%w: there can't be %d teams", ErrInvalid, l.Teams)
+	}
+	teams := settings.TeamColors()
+
+	colors := append([]string{engine.White, engine.Black}, teams...)
+	switch l.Mode {
+	case engine.Classic:
+	case engine.Duet:
+		if rows != engine.DuetSize || cols != engine.DuetSize {
+			return nil, fmt.Errorf("%w: duet board must be %dx%d", ErrInvalid, engine.DuetSize, engine.DuetSize)
+		}
+		colors = []string{engine.Green, engine.White, engine.Black}
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalid, l.Mode)
+	}
+	board := make(engine.Board, rows)
+	for i, row := range l.Board {
+		if len(row) != cols {
+			return nil, fmt.Errorf("%w: row %d must have %d cells like the first one", ErrInvalid, i, cols)
+		}
+		board[i] = make([]engine.Cell, cols)
+		for j, cell := range row {
+			if !slices.Contains(colors, cell.Color) {
+				return nil, fmt.Errorf("%w: unknown color %q", ErrInvalid, cell.Color)
+			}
+			if cell.Image != "" && !validImage(cell.Image) {
+				return nil, fmt.Errorf("%w: %q is not a picture of a set", ErrInvalid, cell.Image)
+			}
+			board[i][j] = engine.Cell{Word: cell.Word, Color: cell.Color, Image: cell.Image}
+			if l.Mode != engine.Duet {
+				continue
+			}
+			for _, side := range []string{engine.Blue, engine.Red} {
+				if !slices.Contains(colors, cell.Keys[side]) {
+					return nil, fmt.Errorf("%w: unknown color %q on the key card of %s", ErrInvalid, cell.Keys[side], side)
+				}
+			}
+			board[i][j].Keys = map[string]string{engine.Blue: cell.Keys[engine.Blue], engine.Red: cell.Keys[engine.Red]}
+		}
+	}
+
+	switch {
+	case slices.Contains(teams, l.First):
+		settings.FirstTeam = l.First
+	case l.First == "":
+		// nobody has moved, so the team with more words is the one to go first
+	default:
+		return nil, fmt.Errorf("%w: unknown first team %q", ErrInvalid, l.First)
+	}
+	settings.Rows, settings.Cols = rows, cols
+	settings.KeyCard = engine.KeyCardFor(rows, cols, settings.NumTeams)
+	var moves []engine.Move
+	for i, m := range l.Moves {
+		move := engine.Move{
+			Time:     m.Time,
+			Kind:     m.Kind,
+			Nickname: m.Player,
+			Team:     m.Team,
+		}
+		if !slices.Contains(teams, m.Team) {
+			return nil, fmt.Errorf("%w: move %d is made by unknown team %q", ErrInvalid, i, m.Team)
+		}
+		switch m.Kind {
+		case engine.MoveClue:
+			if m.Clue == nil {
+				return nil, fmt.Errorf("%w: move %d has no clue", ErrInvalid, i)
+			}
+			move.Clue = &engine.Clue{Team: m.Team, Word: m.Clue.Word, Number: m.Clue.Number}
+		case engine.MoveGuess, engine.MoveTimeout:
+			// a timeout without a word didn't open anything
+			if m.Kind == engine.MoveTimeout && m.Word == "" {
+				break
+			}
+			cell, err := board.Cell(m.Row, m.Col)
+			if err != nil {
+				return nil, fmt.Errorf("%w: move %d: %w", ErrInvalid, i, err)
+			}
+			if m.Word != "" && m.Word != cell.Word {
+				return nil, fmt.Errorf("%w: move %d opens %q, but the cell has %q", ErrInvalid, i, m.Word, cell.Word)
+			}
+			move.Row, move.Col = m.Row, m.Col
+			move.Word, move.Color = cell.Word, cell.Color
+			// in duet the guess is checked against the key card of the side that has given the clue
+			if l.Mode == engine.Duet {
+				giver := engine.Blue
+				if m.Team == engine.Blue {
+					giver = engine.Red
+				}
+				move.Color = cell.Keys[giver]
+			}
+		case engine.MoveEndGuessing:
+		default:
+			return nil, fmt.Errorf("%w: move %d is of unknown kind %q", ErrInvalid, i, m.Kind)
+		}
+		moves = append(moves, move)
+	}
+
+	// the moves open the cells of the board the way they did in the game
+	g := engine.New(board, settings)
+	g.History = moves
+	g = engine.New(g.BoardAt(len(moves)), settings)
+	g.History = moves
+	g.Winner = l.Winner
+	g.Phase = engine.Over
+	// a team that hits the assassin is out of the game, unless it leaves only one team
+	// and the game ends right there
+	if l.Mode != engine.Duet {
+		for _, m := range moves {
+			active := slices.DeleteFunc(slices.Clone(g.Teams), func(t *engine.Team) bool { return t.Eliminated })
+			if m.Kind == engine.MoveGuess && m.Color == engine.Black && len(active) > 2 {
+				g.Team(m.Team).Eliminated = true
+			}
+		}
+	}
+	return g, nil
+}
+
+// validImage tells if the path is a file right inside a picture set, like "animals/cat.svg"
+func validImage(image string) bool {
+	set, file, ok := strings.Cut(image, "/")
+	for _, name := range []string{set, file} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,114 @@
+package gamelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+// cellOf returns the first cell of the color that is not open yet
+func cellOf(t *testing.T, g *engine.Game, color string) (int, int) {
+	t.Helper()
+	for i, row := range g.Board {
+		for j, cell := range row {
+			if cell.Color == color && !cell.IsOpen {
+				return i, j
+			}
+		}
+	}
+	t.Fatalf("no %s cell on the board", color)
+	return 0, 0
+}
+
+func TestThreeTeamsElimination(t *testing.T) {
+	s := engine.DefaultSettings()
+	s.NumTeams = 3
+	s.FirstTeam = engine.Blue
+	s.KeyCard = engine.KeyCardFor(s.Rows, s.Cols, 3)
+	words := make([]string, s.Cells())
+	for i := range words {
+		words[i] = fmt.Sprint("word", i)
+	}
+	g := engine.Deal(words, s)
+
+	spymasters := map[string]*engine.Player{}
+	operatives := map[string]*engine.Player{}
+	for _, color := range s.TeamColors() {
+		spymasters[color] = &engine.Player{ID: color + "s", Nickname: color + "s", Team: color, Role: engine.Spymaster}
+		operatives[color] = &engine.Player{ID: color + "o", Nickname: color + "o", Team: color, Role: engine.Operative}
+		for _, p := range []*engine.Player{spymasters[color], operatives[color]} {
+			if err := g.Seat(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// blue hits the assassin and is out, red and green play on
+	if _, err := g.GiveClue(spymasters[engine.Blue], "clue", 1); err != nil {
+		t.Fatal(err)
+	}
+	row, col := cellOf(t, g, engine.Black)
+	if _, err := g.Guess(operatives[engine.Blue], row, col); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Team(engine.Blue).Eliminated || g.Ended() {
+		t.Fatal("blue should be out and the game should go on")
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, Export("id", g)); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := l.Game()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, team := range g.Teams {
+		if got := imported.Team(team.Color).Eliminated; got != team.Eliminated {
+			t.Errorf("%s eliminated %v after the import, want %v", team.Color, got, team.Eliminated)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	tests := []struct {
+		image string
+		ok    bool
+	}{
+		{"", true},
+		{"animals/cat.svg", true},
+		{"cat.svg", false},
+		{"animals/", false},
+		{"/etc/passwd", false},
+		{"../secret.png", false},
+		{"animals/../../secret.png", false},
+		{"animals/cats/cat.svg", false},
+		{`animals/..\secret.png`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			words := make([]string, engine.DefaultSettings().Cells())
+			for i := range words {
+				words[i] = fmt.Sprint("word", i)
+			}
+			l := Export("id", engine.Deal(words, engine.DefaultSettings()))
+			l.Board[0][0].Image = tt.image
+			_, err := l.Game()
+			if tt.ok && err != nil {
+				t.Errorf("%v, want the picture accepted", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalid) {
+				t.Errorf("got %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
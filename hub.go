@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// send pings to peer with this period, must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// maximum message size allowed from peer
+	maxMessageSize = 4096
+
+	// how many messages can wait for a slow client before it is dropped
+	sendBuffer = 64
+)
+
+// actions the clients can send, every message carries one of them
+const (
+	ActionJoin     = "join"
+	ActionNickname = "nickname"
+	ActionClue     = "clue"
+	ActionGuess    = "guess"
+	ActionRematch  = "rematch"
+)
+
+// client is a single websocket connection to a game, be it a player or someone just watching
+type client struct {
+	game *Game
+	conn *websocket.Conn
+	send chan []byte
+
+	// nil until the client takes a seat
+	player *Player
+}
+
+// command is a decoded message along with the client that has sent it
+type command struct {
+	client  *client
+	payload any
+}
+
+type Nickname struct {
+	PlayerID string
+	GameID   string `json:"gameID"`
+	Nickname string
+}
+
+// decode reads the action of the message and unmarshals the rest of it into the matching type
+func decode(data []byte) (any, error) {
+	var envelope struct {
+		Action string
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	var payload any
+	switch envelope.Action {
+	case ActionJoin:
+		payload = &JoinRequest{}
+	case ActionNickname:
+		payload = &Nickname{}
+	case ActionClue:
+		payload = &Clue{}
+	case ActionGuess:
+		payload = &Guess{}
+	case ActionRematch:
+		payload = &Rematch{}
+	default:
+		return nil, fmt.Errorf("unknown action %q", envelope.Action)
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// readPump turns everything the client sends into commands for the hub.
+// There is one readPump per connection, so nobody waits for anyone else
+func (c *client) readPump() {
+	defer func() {
+		select {
+		case c.game.unregister <- c:
+		case <-c.game.done:
+		}
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Println(err)
+			}
+			return
+		}
+		payload, err := decode(data)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		select {
+		case c.game.moves <- command{client: c, payload: payload}:
+		case <-c.game.done:
+			return
+		}
+	}
+}
+
+// writePump is the only place the connection is written to, so the writes are serialized
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// the hub closed the channel
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			// binary instead of text message was the cause of why it didn't swap the content
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Println(err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// run is the hub of the game: it owns the game state and the set of clients,
+// and handles the commands one at a time in the order they arrive.
+// Once the game has ended and the last client has left, the hub stops and the game is forgotten,
+// unless the room of the game is yet to play the rematch
+func (game *Game) run() {
+	// the timer is checked and shown to everyone every second,
+	// a finished game has no timer, so it stops ticking
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	tick := ticker.C
+	stopTicking := func() {
+		if game.Ended() {
+			ticker.Stop()
+			tick = nil
+		}
+	}
+	game.mu.RLock()
+	stopTicking()
+	game.mu.RUnlock()
+
+	for {
+		select {
+		case c := <-game.register:
+			game.clients[c] = struct{}{}
+
+		case c := <-game.unregister:
+			game.drop(c)
+			game.mu.RLock()
+			over := game.Ended() && len(game.clients) == 0
+			game.mu.RUnlock()
+			if over && !game.latestOfRoom() {
+				game.stop()
+				return
+			}
+
+		case cmd := <-game.moves:
+			// the connection may still be read for a moment after the client is dropped
+			if _, ok := game.clients[cmd.client]; !ok {
+				continue
+			}
+			game.mu.Lock()
+			game.dispatch(cmd)
+			game.save()
+			stopTicking()
+			game.mu.Unlock()
+
+		case now := <-tick:
+			game.mu.Lock()
+			game.tick(now)
+			stopTicking()
+			game.mu.Unlock()
+		}
+	}
+}
+
+// stop removes the finished game from the server along with its players,
+// the record of it stays in the store
+func (game *Game) stop() {
+	gLock.Lock()
+	delete(games, game.ID)
+	close(game.done)
+	gLock.Unlock()
+
+	pLock.Lock()
+	for _, p := range game.Players() {
+		delete(players, p.ID)
+	}
+	pLock.Unlock()
+	log.Println("game", game.ID, "is over, its hub has stopped")
+}
+
+// latestOfRoom tells if the game is the one its room is playing now,
+// it stays even when it's over, that's where the players come back for the rematch
+func (game *Game) latestOfRoom() bool {
+	room, ok := findRoom(game.Room)
+	return ok && room.latest() == game.ID
+}
+
+// drop forgets the client, the seat of the player stays taken though
+func (game *Game) drop(c *client) {
+	if _, ok := game.clients[c]; !ok {
+		return
+	}
+	delete(game.clients, c)
+	close(c.send)
+	if c.player != nil && c.player.client == c {
+		c.player.client = nil
+	}
+}
+
+func (game *Game) dispatch(cmd command) {
+	c := cmd.client
+	switch m := cmd.payload.(type) {
+	case *JoinRequest:
+		game.join(c, m)
+	case *Nickname:
+		game.setNickname(c, m)
+	case *Clue:
+		game.giveClue(c, m)
+	case *Guess:
+		game.guess(c, m)
+	case *Resume:
+		game.resume(c, m)
+	case *Rematch:
+		game.rematch(c, m)
+	}
+}
+
+// sendTo queues the message for the client without ever blocking the hub,
+// a client that can't keep up is dropped
+func (game *Game) sendTo(c *client, msg []byte) {
+	// the send channel of a dropped client is closed
+	if _, ok := game.clients[c]; c == nil || !ok {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		log.Println("client is too slow, dropping it")
+		game.drop(c)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+func TestHubStops(t *testing.T) {
+	tests := []struct {
+		name  string
+		over  bool
+		room  bool
+		stops bool
+	}{
+		{name: "going on"},
+		{name: "over", over: true, stops: true},
+		{name: "over, the room is yet to play the rematch", over: true, room: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := engine.New(nil, engine.DefaultSettings())
+			if tt.over {
+				state.Phase = engine.Over
+			}
+			game := newGame(tt.name, state)
+			if tt.room {
+				room := &Room{ID: tt.name, games: []string{game.ID}}
+				rLock.Lock()
+				rooms[room.ID] = room
+				rLock.Unlock()
+				game.Room = room.ID
+			}
+			gLock.Lock()
+			games[game.ID] = game
+			gLock.Unlock()
+
+			c := &client{game: game, send: make(chan []byte, sendBuffer)}
+			game.register <- c
+			game.unregister <- c
+
+			select {
+			case <-game.done:
+				if !tt.stops {
+					t.Fatal("the hub has stopped")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.stops {
+					t.Fatal("the hub is still running")
+				}
+			}
+			gLock.RLock()
+			_, ok := games[game.ID]
+			gLock.RUnlock()
+			if ok == tt.stops {
+				t.Errorf("game kept %v, want %v", ok, !tt.stops)
+			}
+			if _, ok := <-c.send; ok {
+				t.Error("the send channel of the client is still open")
+			}
+		})
+	}
+}
@@ -1,189 +1,172 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
-	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-)
-
-const (
-	Blue  = "blue"
-	Red   = "red"
-	White = "white"
-	Black = "black"
-)
 
-const (
-	Operative = "o"
-	Spymaster = "s"
+	"github.com/kjedeligmann/codenames/engine"
+	"github.com/kjedeligmann/codenames/gamelog"
+	"github.com/kjedeligmann/codenames/store"
 )
 
-const Size = 5
+type Player struct {
+	*engine.Player
 
-type Cell struct {
-	Word   string
-	Color  string
-	IsOpen bool
+	// nil while the player is disconnected
+	client *client
 }
 
-type Board [Size][Size]Cell
-
-func lineCounter(r io.Reader) (int, error) {
-	buf := make([]byte, 32*1024)
-	count := 0
-	lineSep := []byte{'\n'}
-
-	for {
-		c, err := r.Read(buf)
-		count += bytes.Count(buf[:c], lineSep)
-
-		switch {
-		case err == io.EOF:
-			return count, nil
-
-		case err != nil:
-			return count, err
-		}
-	}
+// Game is a game of codenames together with the connections of everyone involved
+type Game struct {
+	ID string
+	*engine.Game
+
+	// the room the game is played in, if any
+	Room string
+	// the rematch of the game and the seats of the players in it by their IDs in this game,
+	// the hub keeps them
+	next      string
+	nextSeats map[string]string
+
+	// guards the game state, the hub holds it while handling a command
+	mu sync.RWMutex
+
+	moves      chan command
+	register   chan *client
+	unregister chan *client
+	clients    map[*client]struct{}
+	// closed once the hub has stopped, nobody is there to take anything sent to it
+	done chan struct{}
 }
 
-func Words(name string) (words [25]string, e error) {
-	wordlist, err := os.Open(fmt.Sprintf("wordlists/%s.txt", name))
-	if err != nil {
-		return words, err
-	}
-	defer wordlist.Close()
+// NewGame deals a board from the words and starts the hub of the game
+func NewGame(words []string, settings engine.Settings) *Game {
+	return newGame(uuid.New().String(), engine.Deal(words, settings))
+}
 
-	length, err := lineCounter(wordlist)
-	if err != nil {
-		return words, err
-	}
-	// debug
-	log.Println(name)
-	log.Println(length)
-
-	var wordIdcs [25]int
-	present := map[int]struct{}{}
-	for i := range wordIdcs {
-		for {
-			idx := rand.Intn(length)
-			if _, ok := present[idx]; ok {
-				continue
-			}
-			present[idx] = struct{}{}
-			wordIdcs[i] = idx
-			break
-		}
+func newGame(id string, state *engine.Game) *Game {
+	game := &Game{
+		ID:         id,
+		Game:       state,
+		moves:      make(chan command),
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		clients:    map[*client]struct{}{},
+		done:       make(chan struct{}),
 	}
-	slices.Sort(wordIdcs[:])
-	log.Println(wordIdcs)
+	go game.run()
+	return game
+}
 
-	// reopen the file to scan it again
-	again, err := os.Open(fmt.Sprintf("wordlists/%s.txt", name))
+// restoreGames brings back the games that were going on before the restart,
+// so players can reconnect to them with their session tokens
+func restoreGames() {
+	records, err := db.Load()
 	if err != nil {
-		return words, err
+		log.Println(err)
 	}
-	defer again.Close()
-
-	scanner := bufio.NewScanner(again)
-	curr := 0
-	for i := range wordIdcs[len(wordIdcs)-1] + 1 {
-		log.Println(i)
-		if scanner.Scan() == false {
-			return words, scanner.Err()
-		}
-		if i == wordIdcs[curr] {
-			words[curr] = scanner.Text()
-			log.Println(curr, words[curr])
-			curr++
+	for _, r := range records {
+		game := newGame(r.ID, r.Game)
+		game.Room = r.Room
+		for _, p := range game.Players() {
+			players[p.ID] = &Player{Player: p}
 		}
+		games[game.ID] = game
 	}
-	log.Println(words)
-
-	rand.Shuffle(25, func(i, j int) {
-		words[i], words[j] = words[j], words[i]
-	})
-
-	log.Println(words)
-
-	return words, nil
+	log.Println("restored games:", len(records))
 }
 
-func NewBoard(wordlist string) *Board {
-	// shuffle word colors
-	var colors = []string{
-		Blue, Blue, Blue, Blue, Blue, Blue, Blue, Blue, Blue,
-		Red, Red, Red, Red, Red, Red, Red, Red,
-		White, White, White, White, White, White, White,
-		Black,
+// save snapshots the game, the hub calls it after every command
+func (game *Game) save() {
+	if db == nil {
+		return
 	}
-	rand.Shuffle(25, func(i, j int) {
-		colors[i], colors[j] = colors[j], colors[i]
-	})
-
-	words, err := Words(wordlist)
-	if err != nil {
+	if err := db.Save(store.Record{ID: game.ID, Room: game.Room, Game: game.Game}); err != nil {
 		log.Println(err)
-		return &Board{}
-	}
-
-	var b Board
-	var idx int
-	for i := 0; i < Size; i++ {
-		for j := 0; j < Size; j++ {
-			b[i][j].Word = words[idx]
-			b[i][j].Color = colors[idx]
-			idx++
-		}
 	}
-	return &b
 }
 
-type Player struct {
-	ID       string
-	Nickname string
-	Team     string
-	Role     string
-	conn     *websocket.Conn
+// History is the log of the game as served at /game/{id}/history.
+// The key card is only there after the game has ended
+type History struct {
+	ID     string
+	First  string
+	Words  [][]string
+	Board  engine.Board `json:",omitempty"`
+	Winner string       `json:",omitempty"`
+	Moves  []engine.Move
 }
 
-type Team struct {
-	Operative *Player
-	Spymaster *Player
-	WordsLeft int
+func (game *Game) history() History {
+	h := History{
+		ID:     game.ID,
+		First:  game.First,
+		Winner: game.Winner,
+		Moves:  game.History,
+	}
+	for _, row := range game.Board {
+		var words []string
+		for _, cell := range row {
+			words = append(words, cell.Word)
+		}
+		h.Words = append(h.Words, words)
+	}
+	if game.Ended() {
+		h.Board = game.Board
+	}
+	return h
 }
 
-// type Turn struct {
-// 	Team string
-// 	Role string
-// }
+// replay is what the replay page needs to show the board after the given move
+func (game *Game) replay(step int) any {
+	step = max(0, min(step, len(game.History)))
 
-type Game struct {
-	ID     string
-	Board  *Board
-	Red    Team
-	Blue   Team
-	Winner *Team
-	Turn   *Team
-	Clue   *Clue
-	Begun  bool
-	ended  bool
-	moves  chan []byte
-	lobby  map[*websocket.Conn]struct{}
+	// no peeking at the key card while the game is going on
+	role := engine.Operative
+	if game.Ended() {
+		role = engine.Spymaster
+	}
+	return struct {
+		ID      string
+		Role    string
+		Board   engine.Board
+		First   string
+		Step    int
+		Current int
+		Prev    int
+		Next    int
+		Moves   []engine.Move
+		Winner  string
+		Duet    bool
+	}{
+		ID:      game.ID,
+		Role:    role,
+		Board:   game.BoardAt(step),
+		First:   game.First,
+		Step:    step,
+		Current: step - 1,
+		Prev:    max(0, step-1),
+		Next:    min(len(game.History), step+1),
+		Moves:   game.History,
+		Winner:  game.Winner,
+		Duet:    game.Mode == engine.Duet,
+	}
 }
 
 type JoinRequest struct {
@@ -194,7 +177,7 @@ type JoinRequest struct {
 }
 
 const JoinBroadcast = `
-<div id="{{.Team}}{{.Role}}">
+<div id="{{Seat .Team .Role .ID}}">
     {{Role .Role}}: {{.Nickname}}
 </div>
 `
@@ -209,6 +192,33 @@ var JoinFuncMap = template.FuncMap{
 			return "Invalid role"
 		}
 	},
+	// id of the element showing the player, spymaster replaces the join button
+	// while operatives get a div of their own
+	"Seat": func(team, role, id string) string {
+		if role == "s" {
+			return team + role
+		}
+		return "player-" + id
+	},
+	// nicknames of the players who have proposed to open a cell
+	"Nicknames": func(ids []string) string {
+		pLock.RLock()
+		defer pLock.RUnlock()
+		var nicknames []string
+		for _, id := range ids {
+			if player, ok := players[id]; ok {
+				nicknames = append(nicknames, player.Nickname)
+			}
+		}
+		return strings.Join(nicknames, ", ")
+	},
+	// zero is shown as is, but unlimited gets its own sign
+	"ClueNumber": func(n int) string {
+		if n == engine.Unlimited {
+			return "∞"
+		}
+		return strconv.Itoa(n)
+	},
 	// for passing multiple arguments to a template
 	"map": MapTempl,
 
@@ -236,13 +246,14 @@ func MapTempl(pairs ...any) (map[string]any, error) {
 }
 
 const OwnID = `
-<div id="player-id" hidden>{{.ID}}</div>
+<div id="player-id" hidden data-token="{{.Token}}">{{.ID}}</div>
 `
 const EnterNickname = `
-<div id="{{.Team}}{{.Role}}" hx-ext="ws">
+<div id="{{Seat .Team .Role .ID}}" hx-ext="ws">
         <input id="nickname" type="text" placeholder="Nickname">
         <button ws-send
                 hx-vals='js:{
+                "action": "nickname",
                 "playerID": document.getElementById("player-id").textContent,
                 "gameID": window.location.href.split("/")[4],
                 "nickname": document.getElementById("nickname").value,
@@ -254,23 +265,62 @@ const EnterNickname = `
 `
 
 const SomeoneHasJoined = `
-<div id="{{.Team}}{{.Role}}">
+<div id="{{Seat .Team .Role .ID}}">
     Someone has joined...
 </div>
 `
 
 const WordlistsView = `
-<select name="wordlist" id="wordlist">
-    {{ range $i, $name := . }}
-        <option value="{{$name}}">{{$name}}</option>
-    {{ end }}
-</select>
+<span id="wordlists">
+    <span id="wordlist-filters">
+        <select name="language" hx-get="/wl" hx-include="#wordlist-filters" hx-target="#wordlists" hx-swap="outerHTML">
+            <option value="">any language</option>
+            {{ range .Languages }}
+                <option value="{{.}}" {{ if eq . $.Language }}selected{{ end }}>{{.}}</option>
+            {{ end }}
+        </select>
+        <select name="category" hx-get="/wl" hx-include="#wordlist-filters" hx-target="#wordlists" hx-swap="outerHTML">
+            <option value="">any category</option>
+            {{ range .Categories }}
+                <option value="{{.}}" {{ if eq . $.Category }}selected{{ end }}>{{.}}</option>
+            {{ end }}
+        </select>
+    </span>
+    <br>
+    <select name="wordlist" id="wordlist" multiple>
+        {{ range .Groups }}
+        <optgroup label="{{ or .Language "Other" }}">
+        {{ range .Wordlists }}
+            <option value="{{.Name}}" title="{{.Manifest.Description}}" {{ if eq .Name $.First }}selected{{ end }}>
+                {{ with .Manifest }}{{.Name}}{{ with .Category }}, {{.}}{{ end }}{{ with .Difficulty }}, {{.}}{{ end }}{{ end }}
+            </option>
+        {{ end }}
+        </optgroup>
+        {{ end }}
+        {{ if .Pictures }}
+        <optgroup label="Pictures">
+        {{ range .Pictures }}
+            <option value="pictures/{{.}}">{{.}}</option>
+        {{ end }}
+        </optgroup>
+        {{ end }}
+    </select>
+    <br>
+    <span id="weights">
+        Shares of the words:
+        {{ range .Groups }}
+        {{ range .Wordlists }}
+            <label for="weight-{{.Name}}">{{.Manifest.Name}}</label>
+            <input type="number" name="weight-{{.Name}}" id="weight-{{.Name}}" min="0" placeholder="1" style="width: 4em;">
+        {{ end }}
+        {{ end }}
+    </span>
+</span>
 `
 
 type Clue struct {
 	PlayerID string
 	GameID   string
-	Team     string
 	Word     string
 	Number   int
 }
@@ -294,12 +344,41 @@ var games = map[string]*Game{}
 var pLock = sync.RWMutex{}
 var gLock = sync.RWMutex{}
 
+// nil if the games are kept in memory only
+var db store.Store
+
+var dataDir = flag.String("data", "data", "directory to keep the games in, empty to keep them in memory only")
+
 func main() {
+	flag.Parse()
+	if *dataDir != "" {
+		files, err := store.NewFiles(*dataDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db = files
+
+		// the tokens given out before the restart should still be valid
+		if err := loadSecret(filepath.Join(*dataDir, "secret")); err != nil {
+			log.Fatal(err)
+		}
+		restoreGames()
+		restoreRooms()
+	}
+
+	// the wordlists are read once and then only when they change
+	if err := wordlistCache.Reload(); err != nil {
+		log.Println(err)
+	}
+	go wordlistCache.Watch(reloadEvery)
+
 	log.Println("codenames server started")
 	mux := http.NewServeMux()
 
 	// adding a file server for local htmx lib and ws ext
 	mux.Handle("/htmx/", http.FileServer(http.Dir(".")))
+	// and for the picture sets
+	mux.Handle("/pictures/", http.FileServer(http.Dir(".")))
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// send the html with create button with hx-post to /create
@@ -308,36 +387,72 @@ func main() {
 	})
 
 	mux.HandleFunc("GET /wl", func(w http.ResponseWriter, r *http.Request) {
-		list, err := os.ReadDir("wordlists")
-		if err != nil {
-			log.Println(list, err)
-			return
+		infos := wordlistCache.All()
+
+		// the selector can be narrowed down to a language and a category
+		language, category := r.FormValue("language"), r.FormValue("category")
+		var languages, categories []string
+		var shown []WordlistInfo
+		for _, info := range infos {
+			m := info.Manifest
+			if m.Language != "" && !slices.Contains(languages, m.Language) {
+				languages = append(languages, m.Language)
+			}
+			if m.Category != "" && !slices.Contains(categories, m.Category) {
+				categories = append(categories, m.Category)
+			}
+			if (language == "" || m.Language == language) && (category == "" || m.Category == category) {
+				shown = append(shown, info)
+			}
+		}
+		slices.Sort(languages)
+		slices.Sort(categories)
+		groups := groupWordlists(shown)
+		var first string
+		if len(groups) > 0 {
+			first = groups[0].Wordlists[0].Name
 		}
-		log.Print(list, err)
 
-		// execute the template and send it back
-		var names []string
-		for _, file := range list {
-			name := file.Name()
-			if strings.HasSuffix(name, ".txt") {
-				names = append(names, strings.TrimSuffix(name, ".txt"))
-			}
+		// there may be no picture sets at all,
+		// they have no language and show up only when nothing is filtered out
+		var sets []string
+		if language == "" && category == "" {
+			sets = pictureSets()
 		}
-		if err := template.Must(template.New("wl").Parse(WordlistsView)).Execute(w, names); err != nil {
+
+		// execute the template and send it back
+		if err := template.Must(template.New("wl").Parse(WordlistsView)).Execute(w, struct {
+			Languages  []string
+			Categories []string
+			Language   string
+			Category   string
+			Groups     []WordlistGroup
+			First      string
+			Pictures   []string
+		}{languages, categories, language, category, groups, first, sets}); err != nil {
 			log.Println(err)
 			return
 		}
 	})
 
+	handleWordlists(mux)
+	handleRooms(mux)
+	handleScore(mux)
+
 	mux.HandleFunc("GET /game/{id}", func(w http.ResponseWriter, r *http.Request) {
 		gameId := r.PathValue("id")
 		log.Printf("get /game/%s", gameId)
 
-		if game, ok := games[gameId]; ok {
+		gLock.RLock()
+		game, ok := games[gameId]
+		gLock.RUnlock()
+		if ok {
 			gamePage := template.Must(template.New("game").
 				Funcs(JoinFuncMap).
-				ParseFiles("game.html", "teams.html", "board.html", "clue.html"))
+				ParseFiles("game.html", "teams.html", "board.html", "clue.html", "settings.html", "scoreboard.html"))
 
+			game.mu.RLock()
+			defer game.mu.RUnlock()
 			if err := gamePage.ExecuteTemplate(w, "game.html", game); err != nil {
 				log.Println(err)
 				return
@@ -348,30 +463,182 @@ func main() {
 		}
 	})
 
+	mux.HandleFunc("GET /game/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		gLock.RLock()
+		game, ok := games[r.PathValue("id")]
+		gLock.RUnlock()
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		game.mu.RLock()
+		defer game.mu.RUnlock()
+		// anyone can open the page, so it shows no more than the spectators see
+		if game.View(nil) == "" {
+			http.Error(w, "the board is hidden until the game is over", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(game.history()); err != nil {
+			log.Println(err)
+			return
+		}
+	})
+
+	mux.HandleFunc("GET /game/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		gLock.RLock()
+		game, ok := games[r.PathValue("id")]
+		gLock.RUnlock()
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		replayPage := template.Must(template.New("replay").
+			Funcs(JoinFuncMap).
+			ParseFiles("replay.html", "board.html"))
+
+		game.mu.RLock()
+		defer game.mu.RUnlock()
+		// the replay is behind the same gate as the history
+		if game.View(nil) == "" {
+			http.Error(w, "the board is hidden until the game is over", http.StatusForbidden)
+			return
+		}
+
+		// starting from the end, the final board is what people look at first
+		step, err := strconv.Atoi(r.FormValue("step"))
+		if err != nil {
+			step = len(game.History)
+		}
+
+		// stepping through the moves only swaps the replay div
+		name := "replay.html"
+		if r.Header.Get("HX-Request") == "true" {
+			name = "replay"
+		}
+		if err := replayPage.ExecuteTemplate(w, name, game.replay(step)); err != nil {
+			log.Println(err)
+			return
+		}
+	})
+
+	mux.HandleFunc("GET /game/{id}/export", func(w http.ResponseWriter, r *http.Request) {
+		gLock.RLock()
+		game, ok := games[r.PathValue("id")]
+		gLock.RUnlock()
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		game.mu.RLock()
+		defer game.mu.RUnlock()
+		// the log has the whole key card in it
+		if !game.Ended() {
+			http.Error(w, "the game is not over yet", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="codenames-%s.json"`, game.ID))
+		if err := gamelog.Write(w, gamelog.Export(game.ID, game.Game)); err != nil {
+			log.Println(err)
+			return
+		}
+	})
+
+	mux.HandleFunc("POST /import", func(w http.ResponseWriter, r *http.Request) {
+		log.Println("post /import")
+		file, _, err := r.FormFile("log")
+		if err != nil {
+			http.Error(w, "no log file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		gameLog, err := gamelog.Read(file)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		state, err := gameLog.Game()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		// the pictures are checked just like the picture set picked for a new game
+		if err := checkPictures(state.Board); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		// imported game gets a new ID, it is only there to be replayed
+		imported := newGame(uuid.New().String(), state)
+		imported.save()
+		gLock.Lock()
+		games[imported.ID] = imported
+		gLock.Unlock()
+
+		log.Println("imported game", gameLog.ID, "as", imported.ID)
+		// htmx would follow a plain redirect on its own and swap the page into the form
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", "/game/"+imported.ID+"/replay")
+			return
+		}
+		http.Redirect(w, r, "/game/"+imported.ID+"/replay", http.StatusSeeOther)
+	})
+
 	mux.HandleFunc("POST /create", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("post /create")
-		wordlist := r.FormValue("wordlist")
-		if _, err := os.Stat(fmt.Sprintf("wordlists/%s.txt", wordlist)); errors.Is(err, os.ErrNotExist) {
-			// file does not exist
-			// send the client an error somehow?
+		// picture sets come as pictures/{name}
+		set, pictures := strings.CutPrefix(r.FormValue("wordlist"), "pictures/")
+		var source Source
+		if pictures {
+			if !slices.Contains(pictureSets(), set) {
+				http.Error(w, "no such picture set", http.StatusUnprocessableEntity)
+				return
+			}
+			if len(r.Form["wordlist"]) > 1 {
+				http.Error(w, "a picture set can't be mixed with anything else", http.StatusUnprocessableEntity)
+				return
+			}
+			source.Pictures = set
+		} else {
+			var err error
+			if source.Wordlists, err = parseWordlists(r); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		settings, err := parseSettings(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		// the games of a room don't repeat the words of the ones before
+		var room *Room
+		if id := strings.TrimSpace(r.FormValue("room")); id != "" {
+			if room, err = openRoom(id); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		newGame, err := createGame(source, settings, room)
+		if errors.Is(err, ErrNotEnoughWords) {
+			// the board is bigger than what the wordlists picked can fill, the settings can be changed
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		newGame := &Game{
-			ID:    uuid.New().String(),
-			Board: NewBoard(wordlist),
-			Blue: Team{
-				WordsLeft: 9,
-			},
-			Red: Team{
-				WordsLeft: 8,
-			},
-			Winner: nil,
-			Turn:   nil,
-			Clue:   nil,
-			Begun:  false,
-			moves:  make(chan []byte),
-			lobby:  map[*websocket.Conn]struct{}{},
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "couldn't pick the words from the wordlist", http.StatusInternalServerError)
+			return
 		}
+		newGame.save()
 
 		// adding newGame to games map
 		gLock.Lock()
@@ -393,7 +660,6 @@ func main() {
 
 		// for testing purposes
 		log.Println("new game ID", newGame.ID)
-		log.Println("map entry", games[newGame.ID])
 	})
 
 	mux.HandleFunc("/join", func(w http.ResponseWriter, r *http.Request) {
@@ -409,254 +675,351 @@ func main() {
 		_, lobbyGameID, err := conn.ReadMessage()
 		if err != nil {
 			log.Println(err)
+			conn.Close()
 			return
 		}
 		lgid := struct {
 			GameID string `json:"gameID"`
+			Token  string
 		}{}
 		if err = json.Unmarshal(lobbyGameID, &lgid); err != nil {
 			log.Println(err)
+			conn.Close()
 			return
 		}
 		log.Println(lgid)
 
 		// checking if the sought game exists
 		gLock.RLock()
-		_, ok := games[lgid.GameID]
+		game, ok := games[lgid.GameID]
 		gLock.RUnlock()
 		if !ok {
 			log.Printf("No game with ID %s exists", lgid.GameID)
+			conn.Close()
 			return
 		}
 
-		// adding the connection to the lobby for it to be reached somehow even though player has not joined the game
-		// maybe I should reconsider using websockets for this and try SSE instead
-		games[lgid.GameID].lobby[conn] = struct{}{}
-
-		// reading client request to join a particular game with a particular role
-		_, joinData, err := conn.ReadMessage()
-		if err != nil {
-			log.Println(err)
-			return
+		// the connection gets every update of the game even though the client has not joined it,
+		// this way spectators see the same things as the players
+		c := &client{
+			game: game,
+			conn: conn,
+			send: make(chan []byte, sendBuffer),
 		}
-		join := JoinRequest{}
-		if err = json.Unmarshal(joinData, &join); err != nil {
-			log.Println(err)
+		// the game may have just finished with its last client gone
+		select {
+		case game.register <- c:
+		case <-game.done:
+			log.Printf("Game %s is over", lgid.GameID)
+			conn.Close()
 			return
 		}
-		log.Println(join)
 
-		// checking if the sought game exists
-		gLock.RLock()
-		game, ok := games[join.GameID]
-		gLock.RUnlock()
-		if !ok {
-			log.Printf("No game with ID %s exists", join.GameID)
-			return
-		}
-		// for testing purposes
-		log.Println("join gameID", join.GameID)
-		log.Println("game struct", game)
+		// everything else the client sends goes through the hub
+		go c.writePump()
+		go c.readPump()
 
-		// creating a new player with unique ID
-		newPlayer := Player{
-			ID:   uuid.New().String(),
-			Team: join.Team,
-			Role: join.Role,
-			conn: conn,
-		}
-		// for testing purposes
-		log.Println("new player id", newPlayer.ID)
-		log.Println("new player struct", newPlayer)
-
-		// checking if the role is already occupied
-		// adding the newPlayer to the game and players map if it isn't
-		// pLock.Lock()
-		if join.Team == Blue {
-			if join.Role == Operative {
-				if game.Blue.Operative == nil {
-					players[newPlayer.ID] = &newPlayer
-					game.Blue.Operative = &newPlayer
-					log.Println("blue o", game.Blue.Operative)
-				} else {
-					log.Println("blue o is already there")
-					return
-				}
-			} else if join.Role == Spymaster {
-				if game.Blue.Spymaster == nil {
-					players[newPlayer.ID] = &newPlayer
-					game.Blue.Spymaster = &newPlayer
-					log.Println("blue s", game.Blue.Spymaster)
-				} else {
-					log.Println("blue s is already there")
-					return
-				}
-			}
-		} else if join.Team == Red {
-			if join.Role == Operative {
-				if game.Red.Operative == nil {
-					players[newPlayer.ID] = &newPlayer
-					game.Red.Operative = &newPlayer
-					log.Println("red o", game.Red.Operative)
-				} else {
-					log.Println("red o is already there")
-					return
-				}
-			} else if join.Role == Spymaster {
-				if game.Red.Spymaster == nil {
-					players[newPlayer.ID] = &newPlayer
-					game.Red.Spymaster = &newPlayer
-					log.Println("red s", game.Red.Spymaster)
-				} else {
-					log.Println("red s is already there")
-					return
-				}
+		// the client has been here before and wants its seat back
+		if lgid.Token != "" {
+			select {
+			case game.moves <- command{client: c, payload: &Resume{Token: lgid.Token}}:
+			case <-game.done:
 			}
 		}
-		// pLock.Unlock()
+	})
 
-		// delete player from the lobby after they joined - to distinct between players and observers during the game
-		delete(game.lobby, conn)
+	log.Fatal(http.ListenAndServe(":3000", mux))
+}
 
-		// sending the player his ID to place in a player-id div
-		ownID := template.Must(template.New("ownID").Parse(OwnID))
-		var respOwnID bytes.Buffer
-		if err := ownID.Execute(&respOwnID, newPlayer); err != nil {
-			log.Println(err)
-			return
+// parseWordlists reads the wordlists picked for a new game from the creation form with their weights,
+// a wordlist without a weight counts as 1
+func parseWordlists(r *http.Request) ([]Wordlist, error) {
+	var lists []Wordlist
+	var total int
+	for _, name := range r.Form["wordlist"] {
+		if _, ok := wordlistCache.Get(name); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrWordlistNotFound, name)
 		}
-		if err = conn.WriteMessage(websocket.TextMessage, respOwnID.Bytes()); err != nil { // binary instead of text message was the cause of why it didn't swap the content
-			log.Println(err)
-			return
-		}
-
-		// sending the player the input for his nickname
-		var enterNickname bytes.Buffer
-		if err := template.Must(template.New("enter-nickname").
-			Parse(EnterNickname)).
-			Execute(&enterNickname, newPlayer); err != nil {
-			log.Println(err)
-			return
+		weight, err := formInt(r, "weight-"+name, 1)
+		if err != nil {
+			return nil, err
 		}
-		if err = conn.WriteMessage(websocket.TextMessage, enterNickname.Bytes()); err != nil {
-			log.Println(err)
-			return
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of %s can't be negative", name)
 		}
+		lists = append(lists, Wordlist{Name: name, Weight: weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("pick at least one wordlist to take the words from")
+	}
+	return lists, nil
+}
 
-		// sending the players 'someone has joined' div (to everyone except the joined player)
-		var someoneHasJoined bytes.Buffer
-		if err := template.Must(template.New("someonejoined").
-			Parse(SomeoneHasJoined)).
-			Execute(&someoneHasJoined, newPlayer); err != nil {
-			log.Println(err)
-			return
-		}
+// parseSettings reads the settings of a new game from the creation form,
+// whatever is left empty stays as in the classic game
+func parseSettings(r *http.Request) (engine.Settings, error) {
+	settings := engine.DefaultSettings()
+	settings.Mode = r.FormValue("mode")
+	if first := r.FormValue("first-team"); first != "" {
+		settings.FirstTeam = first
+	}
 
-		playersHere := []*Player{
-			game.Blue.Operative,
-			game.Blue.Spymaster,
-			game.Red.Operative,
-			game.Red.Spymaster,
-		}
+	var err error
+	if settings.NumTeams, err = formInt(r, "teams", settings.NumTeams); err != nil {
+		return settings, err
+	}
+	if settings.Rows, err = formInt(r, "rows", settings.Rows); err != nil {
+		return settings, err
+	}
+	if settings.Cols, err = formInt(r, "cols", settings.Cols); err != nil {
+		return settings, err
+	}
 
-		for _, player := range playersHere {
-			if player == nil {
-				continue
-			}
-			// everyone except the one who joins!
-			if player.conn == conn {
-				continue
-			}
-			if err = player.conn.WriteMessage(websocket.TextMessage, someoneHasJoined.Bytes()); err != nil {
-				log.Println(err)
-				return
-			}
-		}
-		for connection := range game.lobby {
-			if connection == nil {
-				continue
-			}
-			if err = connection.WriteMessage(websocket.TextMessage, someoneHasJoined.Bytes()); err != nil {
-				log.Println(err)
-				delete(game.lobby, connection)
-				return
-			}
-		}
-		// receiving the players nickname
-		_, nicknameData, err := conn.ReadMessage()
-		if err != nil {
-			log.Println(err)
-			return
+	// the key card follows the size of the board unless set by hand,
+	// bystanders take the cells left after the teams and the assassins
+	settings.KeyCard = engine.KeyCardFor(settings.Rows, settings.Cols, settings.NumTeams)
+	key := &settings.KeyCard
+	if key.First, err = formInt(r, "first-words", key.First); err != nil {
+		return settings, err
+	}
+	if key.Second, err = formInt(r, "second-words", key.Second); err != nil {
+		return settings, err
+	}
+	if settings.NumTeams == 3 {
+		if key.Third, err = formInt(r, "third-words", key.Third); err != nil {
+			return settings, err
 		}
-		nn := struct {
-			PlayerID string
-			GameID   string `json:"gameID"`
-			Nickname string
-		}{}
-		if err = json.Unmarshal(nicknameData, &nn); err != nil {
-			log.Println(err)
-			return
+	}
+	if key.Assassins, err = formInt(r, "assassins", key.Assassins); err != nil {
+		return settings, err
+	}
+	key.Bystanders = settings.Cells() - key.First - key.Second - key.Third - key.Assassins
+
+	// how many operatives have to click a cell to open it, majority by default
+	if settings.Quorum, err = formInt(r, "quorum", 0); err != nil {
+		return settings, err
+	}
+	if settings.MaxOperatives, err = formInt(r, "max-operatives", 0); err != nil {
+		return settings, err
+	}
+	if settings.Mode == engine.Duet {
+		if settings.Tokens, err = formInt(r, "tokens", engine.DuetTokens); err != nil {
+			return settings, err
 		}
-		log.Println(nn)
+	}
+	settings.Spectators = r.FormValue("spectators")
 
-		// setting tha nickname
-		newPlayer.Nickname = nn.Nickname
+	// clue rules are strict unless relaxed
+	settings.ClueRules.Phrases = r.FormValue("phrases") != ""
+	settings.ClueRules.BoardWords = r.FormValue("board-words") != ""
 
-		// sending the connected players the new player div instead of a button
-		newPlayerDiv := template.Must(template.New("joinBrdcst").
-			Funcs(JoinFuncMap).
-			Parse(JoinBroadcast))
-		var resp bytes.Buffer
-		if err := newPlayerDiv.Execute(&resp, newPlayer); err != nil {
-			log.Println(err)
-			return
-		}
+	// time limits in seconds, no limit if left empty
+	seconds, err := formInt(r, "clue-time", 0)
+	if err != nil {
+		return settings, err
+	}
+	settings.Timers.Clue = time.Duration(seconds) * time.Second
+	if seconds, err = formInt(r, "guess-time", 0); err != nil {
+		return settings, err
+	}
+	settings.Timers.Guess = time.Duration(seconds) * time.Second
+	settings.Timers.Penalty = r.FormValue("penalty")
 
-		// sending the players tha div with tha nickname
-		for _, player := range playersHere {
-			if player == nil {
-				continue
-			}
-			if err = player.conn.WriteMessage(websocket.TextMessage, resp.Bytes()); err != nil { // binary instead of text message was the cause of why it didn't swap the content
-				log.Println(err)
-				return
-			}
+	// a seed from another game makes the same board, a new one is picked otherwise
+	if seed := strings.TrimSpace(r.FormValue("seed")); seed != "" {
+		if settings.Seed, err = strconv.ParseInt(seed, 10, 64); err != nil {
+			return settings, fmt.Errorf("seed is not a number: %q", seed)
 		}
+	} else {
+		settings.Seed = rand.Int63n(maxSeed)
+	}
 
-		// sending the same thing for the lobby
-		// it's just repeating the other way
-		// but this allows spectators potentially the same capabilities that the players, maybe I should indeed switch to SSE
-		for connection := range game.lobby {
-			if connection == nil {
-				continue
-			}
-			if err = connection.WriteMessage(websocket.TextMessage, resp.Bytes()); err != nil {
-				log.Println(err)
-				delete(game.lobby, connection)
-				return
-			}
+	return settings, settings.Validate()
+}
+
+// new seeds are kept short enough to be read out loud
+const maxSeed = 1_000_000_000
+
+// formInt reads a number from the form, def if the field is left empty
+func formInt(r *http.Request, name string, def int) (int, error) {
+	value := strings.TrimSpace(r.FormValue(name))
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: %q", name, value)
+	}
+	return n, nil
+}
+
+// seat wraps the div of a newly joined operative, so that it gets appended to the list of the team's operatives
+// instead of replacing the join button, which stays there for anyone else to join
+func seat(p *engine.Player, div []byte) []byte {
+	if p.Role != engine.Operative {
+		return div
+	}
+	return fmt.Appendf(nil, `<div hx-swap-oob="beforeend:#%s-operatives">%s</div>`, p.Team, div)
+}
+
+// join seats the client with a particular role
+func (game *Game) join(c *client, join *JoinRequest) {
+	log.Println(join)
+	if c.player != nil {
+		log.Println("client has already joined as", c.player.ID)
+		return
+	}
+
+	// creating a new player with unique ID
+	newPlayer := Player{
+		Player: &engine.Player{
+			ID:   uuid.New().String(),
+			Team: join.Team,
+			Role: join.Role,
+		},
+		client: c,
+	}
+	// for testing purposes
+	log.Println("new player id", newPlayer.ID)
+
+	// the engine checks if the role is already occupied
+	// adding the newPlayer to the players map if it isn't
+	if err := game.Seat(newPlayer.Player); err != nil {
+		log.Println(join.Team, join.Role, err)
+		return
+	}
+	pLock.Lock()
+	players[newPlayer.ID] = &newPlayer
+	pLock.Unlock()
+	c.player = &newPlayer
+
+	// sending the player his ID to place in a player-id div
+	// along with the token to get the seat back after reconnecting
+	ownID, err := render(template.Must(template.New("ownID").Parse(OwnID)), game.session(&newPlayer))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.sendTo(c, ownID)
+
+	// sending the player the input for his nickname
+	enterNickname, err := render(template.Must(template.New("enter-nickname").Funcs(JoinFuncMap).Parse(EnterNickname)), newPlayer)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.sendTo(c, seat(newPlayer.Player, enterNickname))
+
+	// sending the players 'someone has joined' div (to everyone except the joined player)
+	someoneHasJoined, err := render(template.Must(template.New("someonejoined").Funcs(JoinFuncMap).Parse(SomeoneHasJoined)), newPlayer)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	someoneHasJoined = seat(newPlayer.Player, someoneHasJoined)
+	for other := range game.clients {
+		if other != c {
+			game.sendTo(other, someoneHasJoined)
 		}
+	}
+
+	// no one else can join the team as an operative
+	if newPlayer.Role == engine.Operative && game.TeamFull(newPlayer.Team) {
+		game.broadcast(fmt.Appendf(nil, `<div id="%s%s"></div>`, newPlayer.Team, newPlayer.Role))
+	}
+
+	// operatives can join the game that is already going on
+	game.catchUp(c)
+}
 
-		// explore the game itself after setting up proper client updates
-		if game.Blue.Operative != nil &&
-			game.Blue.Spymaster != nil &&
-			game.Red.Operative != nil &&
-			game.Red.Spymaster != nil &&
-			game.Begun == false {
+func (game *Game) setNickname(c *client, nn *Nickname) {
+	log.Println(nn)
+	if c.player == nil || nn.PlayerID != c.player.ID {
+		log.Println("Invalid player")
+		return
+	}
+
+	// setting tha nickname
+	c.player.Nickname = nn.Nickname
 
-			// a bit vice a versa, but gets the point across
-			game.Begun = true
-			go game.Begin()
+	// sending the players tha div with tha nickname instead of a button
+	resp, err := render(template.Must(template.New("joinBrdcst").Funcs(JoinFuncMap).Parse(JoinBroadcast)), c.player)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.broadcast(resp)
+
+	// explore the game itself after setting up proper client updates
+	if game.Ready() && !game.Begun() {
+		events, err := game.Start()
+		if err != nil {
+			log.Println(err)
+			return
 		}
-	})
+		game.handle(events)
+	}
+}
 
-	log.Fatal(http.ListenAndServe(":3000", mux))
+func (game *Game) giveClue(c *client, clue *Clue) {
+	// for debugging purposes
+	log.Println(clue)
+
+	if c.player == nil || clue.PlayerID != c.player.ID {
+		log.Println("Invalid player")
+		return
+	}
+
+	events, err := game.GiveClue(c.player.Player, clue.Word, clue.Number)
+	if errors.Is(err, engine.ErrInvalidClue) {
+		// sending the form back to the spymaster along with what's wrong with the clue
+		clueForm, err := game.clueForm(clue.Word, clue.Number, err.Error())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		game.sendTo(c, clueForm)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.handle(events)
+}
+
+func (game *Game) guess(c *client, guess *Guess) {
+	// for debugging purposes
+	log.Println(guess)
+
+	// validating the move
+	if c.player == nil || guess.PlayerID != c.player.ID {
+		log.Println("Invalid player")
+		return
+	}
+
+	// maybe I should add validation of the word itself? e.g. is the guessed word in this cell
+	var events []engine.Event
+	var err error
+	if guess.EndGuessing {
+		events, err = game.EndGuessing(c.player.Player)
+	} else {
+		// the cell opens once enough operatives have clicked it
+		events, err = game.Propose(c.player.Player, guess.Row, guess.Col)
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.handle(events)
 }
 
 const EndGuessing = `
 <span id="end-guessing">
         <button ws-send
                 hx-vals='js:{
+                "action": "guess",
                 "playerID": document.getElementById("player-id").textContent,
                 "gameID": window.location.href.split("/")[4],
                 "endGuessing": true,
@@ -671,438 +1034,426 @@ const EndGuessing = `
 // or should it be the other way around?
 const OpenCell = `
 <button class="cell" id="cell{{.Col}}-{{.Row}}" style="background-color:{{ .Color }};">
-    {{ .Word }}
+    {{ if .Image }}<img class="picture" src="/pictures/{{.Image}}" alt="{{.Word}}">{{ else }}{{ .Word }}{{ end }}
 </button>
 `
 
-func (game *Game) Begin() {
-	// setting blue spymaster as the first player to act
-	game.Turn = &game.Blue
-	next := &game.Red
-
-	for !game.ended {
-		game.sendBoardToEveryone()
-		curr := game.Turn
-
-		// move logic
-
-		// spymaster part
-		// --------------
-		// you should send the spymaster his clue form    execute the ClueForm template
-		clueFormTempl := template.Must(template.New("clue-form").ParseFiles("clue.html"))
-		var clueForm bytes.Buffer
-		if err := clueFormTempl.Execute(&clueForm, nil); err != nil {
-			log.Println(err)
-			return
-		}
-		if err := curr.Spymaster.conn.WriteMessage(websocket.TextMessage, clueForm.Bytes()); err != nil {
-			log.Println(err)
-			return
-		}
-
-		// spymaster begins by giving a clue
-		_, move, err := curr.Spymaster.conn.ReadMessage()
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		var clue *Clue
-		if err := json.Unmarshal(move, &clue); err != nil {
-			log.Println(err)
-			return
-		}
-		// for debugging purposes
-		log.Println(clue)
+const Timer = `
+<span id="timer" style="color:{{.Team}}">{{ if .Left }}{{.Left}}{{ end }}</span>
+`
 
-		// some clue validation should occur
-		pLock.RLock()
-		if player, ok := players[clue.PlayerID]; !ok || player.ID != curr.Spymaster.ID || player != curr.Spymaster {
-			log.Println("Invalid player")
-			pLock.RUnlock()
-			continue
-		}
-		pLock.RUnlock()
+const Winner = `
+<div id="winner">
+    <br>
+    {{ if and .Duet (eq .Color "green") }}
+        All the agents are found, you won!
+    {{ else if and .Duet (eq .Color "black") }}
+        The mission has failed!
+    {{ else }}
+        <span style="color:{{.Color}};">{{.Color}}</span> team won!
+    {{ end }}
+    <br>
+    <a href="/game/{{.GameID}}/replay">Replay the game</a> | <a href="/game/{{.GameID}}/export">Download the log</a>
+    {{ if .Room }}
+        <br>
+        <!-- the next game of the room, with the same players -->
+        <label><input type="checkbox" id="rotate"> rotate spymasters</label>
+        <label><input type="checkbox" id="swap"> swap teams</label>
+        <button ws-send
+                hx-vals='js:{
+                "action": "rematch",
+                "rotate": document.getElementById("rotate").checked,
+                "swap": document.getElementById("swap").checked,
+                }'
+                hx-trigger="click"
+        >Rematch</button>
+    {{ end }}
+</div>
+`
 
-		// if it's valid, set it as game.Clue and send it to everyone
-		clue.Team = players[clue.PlayerID].Team
-		game.Clue = clue
-		game.giveClue()
+// Tokens shows how many turns are left in duet
+const Tokens = `
+<span id="tokens">{{ if eq .Mode "duet" }}Turns left: {{.TokensLeft}}{{ end }}</span>
+`
 
-		// operative part
-		// --------------
-		// then comes the operative that sees the clue and clicks the words
-		// for the span of clues number + 1 we wait for his messages, or he sends endguessing and we break
+// tick passes the turn if the time is out, or shows everyone how much time is left
+func (game *Game) tick(now time.Time) {
+	if game.Deadline.IsZero() {
+		return
+	}
+	if events := game.Timeout(now); events != nil {
+		game.handle(events)
+		game.save()
+		return
+	}
+	game.showTimer(now)
+}
 
-		// somehow I need to think of the way that other players do not disrupt the game
-		// also I think players should be able to select possible words while clicking the button the first time, and everyone should see this (for example, by making its textcolor yellow or something)
+func (game *Game) showTimer(now time.Time) {
+	timer, err := render(template.Must(template.New("timer").Parse(Timer)), struct {
+		Team string
+		Left string
+	}{game.Turn, formatLeft(game.TimeLeft(now))})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.broadcast(timer)
+}
 
-		endGuessingTempl := template.Must(template.New("end-guessing").Parse(EndGuessing))
-		var endGuessing bytes.Buffer
-		if err := endGuessingTempl.Execute(&endGuessing, nil); err != nil {
-			log.Println(err)
-			return
-		}
-		// send an endguessing button to operative and somehow permit him to press the words (while others cannot)
-		if err := curr.Operative.conn.WriteMessage(websocket.TextMessage, endGuessing.Bytes()); err != nil {
-			log.Println(err)
-			return
-		}
+// formatLeft shows the time left as minutes and seconds, and nothing if there is no time limit
+func formatLeft(left time.Duration) string {
+	if left <= 0 {
+		return ""
+	}
+	// rounding up, so that 0:00 means the time is out
+	seconds := int((left + time.Second - 1) / time.Second)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
 
-		// creating a board template for operative
-		boardTmpl := template.Must(template.New("board").
-			Funcs(template.FuncMap{
-				"map": MapTempl,
-				"safe": func(s string) template.CSS {
-					return template.CSS(s)
-				},
-			}).
-			ParseFiles("board.html"))
-
-		// render a clicky board for current operative and send it to him
-		var clickyBoard bytes.Buffer
-		if err := boardTmpl.Execute(&clickyBoard, struct {
-			Role  string
-			Board *Board
-			Turn  bool
-		}{Operative, game.Board, true /* allows clicky buttons */}); err != nil {
-			log.Println(err)
-			return
-		}
-		if err := curr.Operative.conn.WriteMessage(websocket.TextMessage, clickyBoard.Bytes()); err != nil {
-			log.Println(err)
-			return
-		}
+// handle renders the events produced by the engine and sends them to the players
+func (game *Game) handle(events []engine.Event) {
+	for _, event := range events {
+		switch e := event.(type) {
+		case engine.TurnStarted:
+			game.sendBoardToEveryone()
+			game.showTimer(time.Now())
+			if game.Mode == engine.Duet {
+				tokens, err := render(template.Must(template.New("tokens").Parse(Tokens)), game)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				game.broadcast(tokens)
+			}
 
-		// operative can make clue.Number + 1 guesses or less, if he chooses to end guessing
-		// if he guesses incorrect color, his turn ends too
-		for range clue.Number + 1 {
-			// reading the sent guess
-			_, move, err := curr.Operative.conn.ReadMessage()
+			// you should send the spymaster his clue form
+			clueForm, err := game.clueForm("", 1, "")
 			if err != nil {
 				log.Println(err)
-				continue
+				return
 			}
+			game.send(clueForm, game.Team(e.Team).Spymaster)
 
-			var guess *Guess
-			if err := json.Unmarshal(move, &guess); err != nil {
+		case engine.ClueGiven:
+			game.showClue()
+			game.showTimer(time.Now())
+
+			// then comes the operative that sees the clue and clicks the words
+			// also I think players should be able to select possible words while clicking the button the first time, and everyone should see this (for example, by making its textcolor yellow or something)
+			guessers := game.Guessers(e.Clue.Team)
+			endGuessing, err := render(template.Must(template.New("end-guessing").Parse(EndGuessing)), nil)
+			if err != nil {
 				log.Println(err)
 				return
 			}
-			// for debugging purposes
-			log.Println(guess)
-
-			// validating the move
-			pLock.RLock()
-			if player, ok := players[guess.PlayerID]; !ok || player.ID != curr.Operative.ID || player != curr.Operative {
-				log.Println("Invalid player")
-				pLock.RUnlock()
-				continue
-			}
-			pLock.RUnlock()
+			game.send(endGuessing, guessers...)
 
-			if guess.EndGuessing == true {
-				if err := curr.Operative.conn.WriteMessage(websocket.TextMessage,
-					[]byte(`<span id="end-guessing"></span>`)); err != nil {
+			// render a clicky board for current operatives and send it to them
+			for c := range game.clients {
+				if !game.guesser(c) {
+					continue
+				}
+				clickyBoard, err := game.renderBoardFor(c, true /* allows clicky buttons */)
+				if err != nil {
 					log.Println(err)
 					return
 				}
-				break
+				game.sendTo(c, clickyBoard)
 			}
 
-			if guess.Col > Size || guess.Row > Size || guess.Col < 0 || guess.Row < 0 {
-				log.Println("Invalid cell")
-				continue
-			}
-			// maybe I should add validation of the word itself? e.g. is the guessed word in this cell
-
-			// sending the open cell to everyone after validating the move
-			cell := &game.Board[guess.Row][guess.Col]
-
-			if cell.IsOpen {
-				log.Println("Cell is already open")
-				continue
-			}
-			cell.IsOpen = true
-
-			// open cell template usage
-			openCellTmpl := template.Must(template.New("open-cell").Parse(OpenCell))
-			var openCell bytes.Buffer
-			if err := openCellTmpl.Execute(&openCell, struct {
+		case engine.CellOpened:
+			openCell, err := render(template.Must(template.New("open-cell").Parse(OpenCell)), struct {
 				Col   int
 				Row   int
 				Color string
 				Word  string
-			}{guess.Col, guess.Row, cell.Color, cell.Word}); err != nil {
+				Image string
+			}{e.Col, e.Row, e.Cell.Color, e.Cell.Word, e.Cell.Image})
+			if err != nil {
 				log.Println(err)
 				return
 			}
-			playersHere := []*Player{
-				game.Blue.Operative,
-				game.Blue.Spymaster,
-				game.Red.Operative,
-				game.Red.Spymaster,
-			}
-			for _, player := range playersHere {
-				if player == nil {
-					log.Println("somehow one of the players isn't here")
-					return
-				}
-				if err := player.conn.WriteMessage(websocket.TextMessage, openCell.Bytes()); err != nil {
-					log.Println(err)
-					return
+			for c := range game.clients {
+				if game.view(c) != "" {
+					game.sendTo(c, openCell)
 				}
 			}
 
-			for connection := range game.lobby {
-				if connection == nil {
-					continue
-				}
-				if err := connection.WriteMessage(websocket.TextMessage, openCell.Bytes()); err != nil {
-					log.Println(err)
-					delete(game.lobby, connection)
-					return
-				}
-			}
+		case engine.CellProposed:
+			game.sendCell(e.Row, e.Col)
 
-			// evaluating the move
-			var wrong bool
-			if cell.Color == curr.Operative.Team {
-				curr.WordsLeft--
-				if curr.WordsLeft == 0 {
-					game.Winner = curr
-				}
-			} else {
-				wrong = true
-				if cell.Color == next.Operative.Team {
-					next.WordsLeft--
-					if next.WordsLeft == 0 {
-						game.Winner = next
-					}
-				} else if cell.Color == Black {
-					game.Winner = next
-				}
-			}
+		case engine.CellMarked:
+			game.sendCell(e.Row, e.Col)
 
-			// if someone has won
-			if game.Winner != nil {
-				// send everyone the spymaster board
-				boardTmpl := template.Must(template.New("board").
-					Funcs(template.FuncMap{
-						"map": MapTempl,
-						"safe": func(s string) template.CSS {
-							return template.CSS(s)
-						},
-					}).
-					ParseFiles("board.html"))
-
-				// after game ends, everyone should see the remaining words to have a chat about it
-				var spymasterBoard bytes.Buffer
-				if err := boardTmpl.Execute(&spymasterBoard, struct {
-					Role  string
-					Board *Board
-					Turn  bool
-				}{Spymaster, game.Board, false}); err != nil {
-					log.Println(err)
-					return
-				}
-				playersHere := []*Player{
-					game.Blue.Operative,
-					game.Blue.Spymaster,
-					game.Red.Operative,
-					game.Red.Spymaster,
-				}
-				for _, player := range playersHere {
-					if player == nil {
-						log.Println("somehow one of the players isn't here")
-						return
-					}
-					if err := player.conn.WriteMessage(websocket.TextMessage, spymasterBoard.Bytes()); err != nil {
-						log.Println(err)
-						return
-					}
-				}
+		case engine.TeamEliminated:
+			game.broadcast(fmt.Appendf(nil, `<span id="%s-status"> hit the assassin and is out of the game</span>`, e.Team))
 
-				for connection := range game.lobby {
-					if connection == nil {
-						continue
-					}
-					if err := connection.WriteMessage(websocket.TextMessage, spymasterBoard.Bytes()); err != nil {
-						log.Println(err)
-						delete(game.lobby, connection)
-						return
-					}
-				}
+		case engine.TimedOut:
+			log.Println(e.Team, "team has run out of time")
 
-				// send the info about who won
-				winnerTmpl := template.Must(template.New("winner").Parse(Winner))
-				var winner bytes.Buffer
-				if err := winnerTmpl.Execute(&winner, struct {
-					Color string
-				}{game.Winner.Operative.Team}); err != nil {
-					log.Println(err)
-					return
-				}
+		case engine.TurnEnded:
+			// remove the endguessing button and send the empty clue to everyone
+			game.send([]byte(`<span id="end-guessing"></span>`), game.Guessers(e.Team)...)
+			game.showClue()
 
-				for _, player := range playersHere {
-					if player == nil {
-						log.Println("somehow one of the players isn't here")
-						return
-					}
-					if err := player.conn.WriteMessage(websocket.TextMessage, winner.Bytes()); err != nil {
-						log.Println(err)
-						return
-					}
-				}
-
-				for connection := range game.lobby {
-					if connection == nil {
-						continue
-					}
-					if err := connection.WriteMessage(websocket.TextMessage, winner.Bytes()); err != nil {
-						log.Println(err)
-						delete(game.lobby, connection)
-					}
-				}
-
-				// finally! end of the game
-				game.ended = true
+		case engine.GameOver:
+			// after game ends, everyone should see the remaining words to have a chat about it
+			spymasterBoard, err := game.renderBoard(game.Board, engine.Spymaster, false)
+			if err != nil {
+				log.Println(err)
+				return
 			}
-
-			if wrong {
-				break
+			game.broadcast(spymasterBoard)
+
+			// send the info about who won
+			winner, err := render(template.Must(template.New("winner").Parse(Winner)), struct {
+				Color  string
+				GameID string
+				Duet   bool
+				Room   string
+			}{e.Winner, game.ID, game.Mode == engine.Duet, game.Room})
+			if err != nil {
+				log.Println(err)
+				return
 			}
+			game.broadcast(winner)
+			// the games of a room add up to a series
+			game.showScore()
+			game.broadcast([]byte(`<span id="end-guessing"></span>`))
+			game.showClue()
+			game.showTimer(time.Now())
 		}
+	}
+}
 
-		// remove the endguessing button
-		if err := curr.Operative.conn.WriteMessage(websocket.TextMessage,
-			[]byte(`<span id="end-guessing"></span>`)); err != nil {
+func (game *Game) sendBoardToEveryone() {
+	// the board depends on the player role (and their side in duet), spectators get what the settings allow
+	for c := range game.clients {
+		board, err := game.renderBoardFor(c, false)
+		if err != nil {
 			log.Println(err)
 			return
 		}
-
-		// resetting everything
-		// sending the empty clue to everyone
-		next = curr
-		game.changeTurn()
-		game.Clue = nil
-		game.giveClue()
+		game.sendTo(c, board)
 	}
 }
 
-const Winner = `
-<div id="winner">
-    <br>
-    <span style="color:{{.Color}};">{{.Color}}</span> team won!
-</div>
-`
+// view returns the role whose board the client sees, or nothing for spectators who aren't allowed to see it
+func (game *Game) view(c *client) string {
+	return game.View(seated(c))
+}
 
-func (game *Game) changeTurn() {
-	if game.Turn == &game.Blue {
-		game.Turn = &game.Red
-	} else {
-		game.Turn = &game.Blue
+// seated returns the player of the client, nil for spectators
+func seated(c *client) *engine.Player {
+	if c.player == nil {
+		return nil
 	}
+	return c.player.Player
 }
 
-func (game *Game) sendBoardToEveryone() {
-	// render two separate boards for two kinds of players
-	boardTmpl := template.Must(template.New("board").
-		Funcs(template.FuncMap{
-			"map": MapTempl,
-			"safe": func(s string) template.CSS {
-				return template.CSS(s)
-			},
-		}).
+// guesser reports whether the client is to guess the current clue
+func (game *Game) guesser(c *client) bool {
+	p := seated(c)
+	if p == nil || game.Phase != engine.Guessing {
+		return false
+	}
+	for _, g := range game.Guessers(game.Turn) {
+		if g.ID == p.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// sendCell sends the cell to everyone, it is clickable only for the players who are guessing
+func (game *Game) sendCell(row, col int) {
+	cellTmpl := template.Must(template.New("cell").
+		Funcs(JoinFuncMap).
 		ParseFiles("board.html"))
 
-	var spymasterBoard, operativeBoard bytes.Buffer
-	if err := boardTmpl.Execute(&spymasterBoard, struct {
-		Role  string
-		Board *Board
-		Turn  bool
-	}{Spymaster, game.Board, false}); err != nil {
+	if _, err := game.Board.Cell(row, col); err != nil {
 		log.Println(err)
 		return
 	}
-	if err := boardTmpl.Execute(&operativeBoard, struct {
-		Role  string
-		Board *Board
-		Turn  bool
-	}{Operative, game.Board, false}); err != nil {
+	for c := range game.clients {
+		role := game.view(c)
+		if role == "" {
+			continue
+		}
+		// in duet everyone sees the cell on their own key card
+		cell, err := game.BoardFor(seated(c)).Cell(row, col)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		msg, err := renderNamed(cellTmpl, "cell", map[string]any{
+			"Cell": *cell,
+			"Row":  row,
+			"Col":  col,
+			"Role": role,
+			"Turn": game.guesser(c),
+		})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		game.sendTo(c, msg)
+	}
+}
+
+// catchUp sends the client the current state of the game it has missed
+func (game *Game) catchUp(c *client) {
+	if !game.Begun() {
+		return
+	}
+	p := c.player
+
+	// everyone sees the key card after the game ends
+	ourTurn := p != nil && !game.Ended() && p.Team == game.Turn
+	giving := ourTurn && p.Role == engine.Spymaster && game.Phase == engine.Giving
+	guessing := game.guesser(c)
+
+	board, err := game.renderBoardFor(c, guessing)
+	if err != nil {
 		log.Println(err)
 		return
 	}
+	game.sendTo(c, board)
 
-	// send those boards depending on the player role (observers in the lobby get the operative's view)
-	playersHere := []*Player{
-		game.Blue.Operative,
-		game.Blue.Spymaster,
-		game.Red.Operative,
-		game.Red.Spymaster,
+	var clue []byte
+	if giving {
+		clue, err = game.clueForm("", 1, "")
+	} else {
+		clue, err = render(template.Must(template.New("clue").Funcs(JoinFuncMap).ParseFiles("clue.html")), game.Clue)
 	}
-	for _, player := range playersHere {
-		if player == nil {
-			log.Println("somehow one of the players isn't here")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.sendTo(c, clue)
+
+	if guessing {
+		endGuessing, err := render(template.Must(template.New("end-guessing").Parse(EndGuessing)), nil)
+		if err != nil {
+			log.Println(err)
 			return
 		}
-		var resp bytes.Buffer
-		if player.Role == Operative {
-			resp = operativeBoard
-		} else {
-			resp = spymasterBoard
-		}
-		if err := player.conn.WriteMessage(websocket.TextMessage, resp.Bytes()); err != nil {
+		game.sendTo(c, endGuessing)
+	}
+
+	if game.Mode == engine.Duet {
+		tokens, err := render(template.Must(template.New("tokens").Parse(Tokens)), game)
+		if err != nil {
 			log.Println(err)
 			return
 		}
+		game.sendTo(c, tokens)
 	}
 
-	for connection := range game.lobby {
-		if connection == nil {
-			continue
+	if left := game.TimeLeft(time.Now()); left > 0 {
+		timer, err := render(template.Must(template.New("timer").Parse(Timer)), struct {
+			Team string
+			Left string
+		}{game.Turn, formatLeft(left)})
+		if err != nil {
+			log.Println(err)
+			return
 		}
-		if err := connection.WriteMessage(websocket.TextMessage, operativeBoard.Bytes()); err != nil {
+		game.sendTo(c, timer)
+	}
+
+	if game.Ended() {
+		winner, err := render(template.Must(template.New("winner").Parse(Winner)), struct {
+			Color  string
+			GameID string
+			Duet   bool
+			Room   string
+		}{game.Winner, game.ID, game.Mode == engine.Duet, game.Room})
+		if err != nil {
 			log.Println(err)
-			delete(game.lobby, connection)
 			return
 		}
+		game.sendTo(c, winner)
 	}
 }
 
-func (game *Game) giveClue() {
-	clueTmpl := template.Must(template.New("clue").ParseFiles("clue.html"))
-	var clue bytes.Buffer
-	if err := clueTmpl.Execute(&clue, game.Clue); err != nil {
+// clueForm renders the form for the spymaster of the current team, the numbers
+// to choose from go up to the words the team has left, plus zero and unlimited
+func (game *Game) clueForm(word string, number int, errMsg string) ([]byte, error) {
+	numbers := []int{engine.Unlimited}
+	for i := range game.Team(game.Turn).WordsLeft + 1 {
+		numbers = append(numbers, i)
+	}
+	return render(template.Must(template.New("clue-form").Funcs(JoinFuncMap).ParseFiles("clue.html")), struct {
+		Word    string
+		Number  int
+		Numbers []int
+		Error   string
+	}{word, number, numbers, errMsg})
+}
+
+func (game *Game) showClue() {
+	clue, err := render(template.Must(template.New("clue").Funcs(JoinFuncMap).ParseFiles("clue.html")), game.Clue)
+	if err != nil {
 		log.Println(err)
 		return
 	}
+	game.broadcast(clue)
+}
 
-	playersHere := []*Player{
-		game.Blue.Operative,
-		game.Blue.Spymaster,
-		game.Red.Operative,
-		game.Red.Spymaster,
-	}
-	for _, player := range playersHere {
-		if player == nil {
+func (game *Game) renderBoard(board engine.Board, role string, turn bool) ([]byte, error) {
+	boardTmpl := template.Must(template.New("board").
+		Funcs(JoinFuncMap).
+		ParseFiles("board.html"))
+
+	return render(boardTmpl, struct {
+		Role  string
+		Board engine.Board
+		Turn  bool
+		First string
+	}{role, board, turn, game.First})
+}
+
+// renderBoardFor renders the board the way the client sees it, turn makes it clickable
+func (game *Game) renderBoardFor(c *client, turn bool) ([]byte, error) {
+	return game.renderBoard(game.BoardFor(seated(c)), game.view(c), turn)
+}
+
+// send sends the message to the seated players who are connected
+func (game *Game) send(msg []byte, ps ...*engine.Player) {
+	pLock.RLock()
+	defer pLock.RUnlock()
+	for _, p := range ps {
+		player, ok := players[p.ID]
+		if !ok {
 			log.Println("somehow one of the players isn't here")
-			return
-		}
-		if err := player.conn.WriteMessage(websocket.TextMessage, clue.Bytes()); err != nil {
-			log.Println(err)
-			return
+			continue
 		}
+		game.sendTo(player.client, msg)
 	}
+}
 
-	for connection := range game.lobby {
-		if connection == nil {
-			continue
-		}
-		if err := connection.WriteMessage(websocket.TextMessage, clue.Bytes()); err != nil {
-			log.Println(err)
-			delete(game.lobby, connection)
-			return
-		}
+// broadcast sends the message to everyone connected to the game
+func (game *Game) broadcast(msg []byte) {
+	for c := range game.clients {
+		game.sendTo(c, msg)
+	}
+}
+
+func render(tmpl *template.Template, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderNamed is render for a template defined among the others in a file
+func renderNamed(tmpl *template.Template, name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+// picture files that can be put on the cells
+var pictureExts = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg"}
+
+// Pictures picks n random pictures from the picture set, leaving out the excluded ones,
+// the same seed picks the same pictures. They are returned as file names inside the set's directory
+func Pictures(set string, n int, seed int64, exclude map[string]bool) ([]string, error) {
+	rng := rand.New(rand.NewSource(seed))
+
+	list, err := os.ReadDir(filepath.Join("pictures", set))
+	if err != nil {
+		return nil, err
+	}
+	// ReadDir sorts the files by name, so they go in the same order every time
+	var files []string
+	for _, file := range list {
+		if !file.IsDir() && !exclude[file.Name()] && slices.Contains(pictureExts, strings.ToLower(filepath.Ext(file.Name()))) {
+			files = append(files, file.Name())
+		}
+	}
+	if len(files) < n {
+		return nil, fmt.Errorf("%w: picture set %s has %d pictures, but the board needs %d", ErrNotEnoughWords, set, len(files), n)
+	}
+
+	pictures := make([]string, n)
+	for i, idx := range rng.Perm(len(files))[:n] {
+		pictures[i] = files[idx]
+	}
+	return pictures, nil
+}
+
+// pictureSets returns the picture sets there are, every directory in pictures is one
+func pictureSets() []string {
+	dirs, err := os.ReadDir("pictures")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println(err)
+	}
+	var sets []string
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			sets = append(sets, dir.Name())
+		}
+	}
+	return sets
+}
+
+// showPictures puts the pictures of the set on the board dealt with their file names as words,
+// every cell keeps the name without the extension as its word
+func showPictures(board engine.Board, set string) {
+	for i := range board {
+		for j := range board[i] {
+			cell := &board[i][j]
+			cell.Image = set + "/" + cell.Word
+			cell.Word = strings.TrimSuffix(cell.Word, filepath.Ext(cell.Word))
+		}
+	}
+}
+
+// checkPictures makes sure that every picture on the board is a file of one of the picture sets there are,
+// a game log could otherwise point the pages at anything
+func checkPictures(board engine.Board) error {
+	sets := pictureSets()
+	for i := range board {
+		for _, cell := range board[i] {
+			if cell.Image == "" {
+				continue
+			}
+			set, file, _ := strings.Cut(cell.Image, "/")
+			if !slices.Contains(sets, set) || file != filepath.Base(file) ||
+				!slices.Contains(pictureExts, strings.ToLower(filepath.Ext(file))) {
+				return fmt.Errorf("no such picture %q", cell.Image)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+func TestCheckPictures(t *testing.T) {
+	tests := []struct {
+		image string
+		ok    bool
+	}{
+		{"", true},
+		{"animals/cat.svg", true},
+		{"animals/notes.txt", false},
+		{"nosuchset/cat.svg", false},
+		{"animals/../../main.go", false},
+		{"../animals/cat.svg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			board := engine.Board{{{Word: "cat", Image: tt.image}}}
+			if err := checkPictures(board); (err == nil) != tt.ok {
+				t.Errorf("got %v, want it accepted: %v", err, tt.ok)
+			}
+		})
+	}
+}
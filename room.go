@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/kjedeligmann/codenames/engine"
+	"github.com/kjedeligmann/codenames/store"
+)
+
+// room names go into links, so they are kept as simple as the names of wordlists
+var roomName = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// Room is a group of players who play one game after another at the same link.
+// It remembers the words of its boards, so that the next ones don't repeat them
+// until the wordlists run out, and what the boards are made from, for a rematch
+type Room struct {
+	ID string
+
+	mu sync.Mutex
+	// IDs of the games played in the room, the last one is the latest
+	games []string
+	// the words that have been on the boards since the deck was last reset
+	used   map[string]bool
+	source Source
+	score  store.Score
+}
+
+var rooms = map[string]*Room{}
+var rLock = sync.Mutex{}
+
+// openRoom returns the room, making a new one if there is no such room yet
+func openRoom(id string) (*Room, error) {
+	if !roomName.MatchString(id) {
+		return nil, fmt.Errorf("%q can't be the name of a room, use letters, digits, - and _", id)
+	}
+	rLock.Lock()
+	defer rLock.Unlock()
+	room, ok := rooms[id]
+	if !ok {
+		room = &Room{ID: id, used: map[string]bool{}}
+		rooms[id] = room
+	}
+	return room, nil
+}
+
+func findRoom(id string) (*Room, bool) {
+	rLock.Lock()
+	defer rLock.Unlock()
+	room, ok := rooms[id]
+	return room, ok
+}
+
+// restoreRooms brings back the rooms that were there before the restart
+func restoreRooms() {
+	records, err := db.LoadRooms()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, r := range records {
+		room := &Room{
+			ID:     r.ID,
+			games:  r.Games,
+			used:   map[string]bool{},
+			source: Source{Wordlists: r.Wordlists, Pictures: r.Pictures},
+			score:  r.Score,
+		}
+		for _, word := range r.Used {
+			room.used[word] = true
+		}
+		rooms[room.ID] = room
+	}
+	log.Println("restored rooms:", len(records))
+}
+
+// save snapshots the room, it is called with the room locked after every change
+func (room *Room) save() {
+	if db == nil {
+		return
+	}
+	r := store.RoomRecord{
+		ID:        room.ID,
+		Games:     room.games,
+		Wordlists: room.source.Wordlists,
+		Pictures:  room.source.Pictures,
+		Score:     room.score,
+	}
+	for word := range room.used {
+		r.Used = append(r.Used, word)
+	}
+	slices.Sort(r.Used)
+	if err := db.SaveRoom(r); err != nil {
+		log.Println(err)
+	}
+}
+
+// pick picks the words for the next board of the room from the source, leaving out
+// the words the room has already seen. When there aren't enough new words left,
+// the deck starts over and every word can come up again
+func (room *Room) pick(source Source, n int, seed int64) ([]string, error) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	words, err := source.pick(n, seed, room.used)
+	if errors.Is(err, ErrNotEnoughWords) && len(room.used) > 0 {
+		log.Println("room", room.ID, "has run out of new words, starting the deck over")
+		room.used = map[string]bool{}
+		words, err = source.pick(n, seed, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, word := range words {
+		room.used[word] = true
+	}
+	room.save()
+	return words, nil
+}
+
+// add puts the game into the room as the latest one, the next game is made from the same source
+func (room *Room) add(gameID string, source Source) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.games = append(room.games, gameID)
+	room.source = source
+	room.save()
+}
+
+// latest returns the ID of the game the room is playing now, or has played last
+func (room *Room) latest() string {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if len(room.games) == 0 {
+		return ""
+	}
+	return room.games[len(room.games)-1]
+}
+
+// nextSource returns what the next board of the room is made from
+func (room *Room) nextSource() Source {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.source
+}
+
+// reset lets every word come up again
+func (room *Room) reset() {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.used = map[string]bool{}
+	room.save()
+}
+
+// createGame deals a new game from the source, in the room if there is one
+func createGame(source Source, settings engine.Settings, room *Room) (*Game, error) {
+	var words []string
+	var err error
+	if room != nil {
+		words, err = room.pick(source, settings.Cells(), settings.Seed)
+	} else {
+		words, err = source.pick(settings.Cells(), settings.Seed, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	game := NewGame(words, settings)
+	if source.Pictures != "" {
+		showPictures(game.Board, source.Pictures)
+	}
+	if room != nil {
+		game.Room = room.ID
+		room.add(game.ID, source)
+	}
+	return game, nil
+}
+
+// Rematch is sent by a player of a finished game in a room to play the next one with the same players
+type Rematch struct {
+	// the spymasters hand their seats over to the operatives of their teams
+	Rotate bool
+	// every team takes the place of the next one, in a game of two they just swap
+	Swap bool
+}
+
+// reseat seats the players of the finished game in the next one, every player gets a new ID,
+// which the returned map gives by the old one. Only the players who are still there take part
+func reseat(old, next *engine.Game, r *Rematch, present func(*engine.Player) bool) map[string]string {
+	seats := map[string]string{}
+	for i, team := range old.Teams {
+		color := team.Color
+		if r.Swap {
+			color = old.Teams[(i+1)%len(old.Teams)].Color
+		}
+		var members []*engine.Player
+		if team.Spymaster != nil {
+			members = append(members, team.Spymaster)
+		}
+		members = append(members, team.Operatives...)
+		members = slices.DeleteFunc(members, func(p *engine.Player) bool { return !present(p) })
+		// the first operative becomes the spymaster, and the spymaster goes to the end of the line
+		if r.Rotate && len(members) > 1 {
+			members = append(members[1:], members[0])
+		}
+
+		for j, p := range members {
+			seat := &engine.Player{
+				ID:       uuid.New().String(),
+				Nickname: p.Nickname,
+				Team:     color,
+				Role:     engine.Operative,
+			}
+			if j == 0 {
+				seat.Role = engine.Spymaster
+			}
+			if err := next.Seat(seat); err != nil {
+				log.Println(p.Nickname, "can't take a seat in the rematch:", err)
+				continue
+			}
+			pLock.Lock()
+			players[seat.ID] = &Player{Player: seat}
+			pLock.Unlock()
+			seats[p.ID] = seat.ID
+		}
+	}
+	return seats
+}
+
+// rematch starts the next game of the room with the players of this one and sends everyone there.
+// If the room has already moved on, they are just sent to the game it is playing now
+func (game *Game) rematch(c *client, r *Rematch) {
+	if c.player == nil || !game.Ended() || game.Room == "" {
+		log.Println("no rematch for this game")
+		return
+	}
+	room, ok := findRoom(game.Room)
+	if !ok {
+		log.Println("no room", game.Room)
+		return
+	}
+
+	if latest := room.latest(); latest != game.ID {
+		gLock.RLock()
+		next, ok := games[latest]
+		gLock.RUnlock()
+		if ok {
+			game.sendTo(c, game.nextGame(next.ID, c))
+		}
+		return
+	}
+
+	settings := game.Settings
+	settings.Seed = rand.Int63n(maxSeed)
+	next, err := createGame(room.nextSource(), settings, room)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	next.mu.Lock()
+	game.next = next.ID
+	game.nextSeats = reseat(game.Game, next.Game, r, func(p *engine.Player) bool {
+		pLock.RLock()
+		defer pLock.RUnlock()
+		player, ok := players[p.ID]
+		return ok && player.client != nil
+	})
+	// the players already have their nicknames, so the game can start right away
+	if next.Ready() {
+		events, err := next.Start()
+		if err != nil {
+			log.Println(err)
+		}
+		next.handle(events)
+	}
+	next.save()
+	next.mu.Unlock()
+
+	gLock.Lock()
+	games[next.ID] = next
+	gLock.Unlock()
+	log.Println("rematch of", game.ID, "is", next.ID)
+
+	for other := range game.clients {
+		game.sendTo(other, game.nextGame(next.ID, other))
+	}
+}
+
+// nextGame sends the client over to the next game of the room,
+// with the session token of their seat there if they have one
+func (game *Game) nextGame(id string, c *client) []byte {
+	var token string
+	if c.player != nil && id == game.next {
+		if seat, ok := game.nextSeats[c.player.ID]; ok {
+			token = sign(id, seat)
+		}
+	}
+	msg, err := render(template.Must(template.New("next-game").Parse(NextGame)), struct {
+		ID    string
+		Token string
+	}{id, token})
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return msg
+}
+
+// NextGame makes the page go to the next game, see game.html
+const NextGame = `
+<div id="next-game" data-game="{{.ID}}" data-token="{{.Token}}"></div>
+`
+
+// RoomView is what the room page shows
+type RoomView struct {
+	ID    string
+	Used  int
+	Games []string
+	Score Scoreboard
+}
+
+func (room *Room) view() RoomView {
+	score := room.scoreboard()
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	games := slices.Clone(room.games)
+	// the latest game goes first
+	slices.Reverse(games)
+	return RoomView{ID: room.ID, Used: len(room.used), Games: games, Score: score}
+}
+
+// handleRooms adds the room page and the way to reset the deck of the room
+func handleRooms(mux *http.ServeMux) {
+	mux.HandleFunc("GET /room/{id}", func(w http.ResponseWriter, r *http.Request) {
+		room, ok := findRoom(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		page, err := render(template.Must(template.ParseFiles("room.html", "scoreboard.html")), room.view())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		w.Write(page)
+	})
+
+	// the link to share with the players, it always leads to the game the room is playing now
+	mux.HandleFunc("GET /room/{id}/play", func(w http.ResponseWriter, r *http.Request) {
+		room, ok := findRoom(r.PathValue("id"))
+		if !ok || room.latest() == "" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Redirect(w, r, "/game/"+room.latest(), http.StatusSeeOther)
+	})
+
+	mux.HandleFunc("POST /room/{id}/reset", func(w http.ResponseWriter, r *http.Request) {
+		room, ok := findRoom(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		room.reset()
+		log.Println("reset the deck of room", room.ID)
+		deck, err := renderNamed(template.Must(template.ParseFiles("room.html", "scoreboard.html")), "deck", room.view())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		w.Write(deck)
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+// seatsOf returns the nicknames of the players of every team, the spymaster first
+func seatsOf(g *engine.Game) map[string][]string {
+	teams := map[string][]string{}
+	for _, team := range g.Teams {
+		var nicknames []string
+		if team.Spymaster != nil {
+			nicknames = append(nicknames, team.Spymaster.Nickname)
+		}
+		for _, p := range team.Operatives {
+			nicknames = append(nicknames, p.Nickname)
+		}
+		teams[team.Color] = nicknames
+	}
+	return teams
+}
+
+func TestReseat(t *testing.T) {
+	tests := []struct {
+		name    string
+		rematch Rematch
+		teams   int
+		// the players who have left before the rematch
+		absent []string
+		want   map[string][]string
+	}{
+		{
+			name: "same seats",
+			want: map[string][]string{engine.Blue: {"bs", "bo1", "bo2"}, engine.Red: {"rs", "ro"}},
+		},
+		{
+			name:    "rotate",
+			rematch: Rematch{Rotate: true},
+			want:    map[string][]string{engine.Blue: {"bo1", "bo2", "bs"}, engine.Red: {"ro", "rs"}},
+		},
+		{
+			name:    "swap",
+			rematch: Rematch{Swap: true},
+			want:    map[string][]string{engine.Blue: {"rs", "ro"}, engine.Red: {"bs", "bo1", "bo2"}},
+		},
+		{
+			name:    "rotate and swap",
+			rematch: Rematch{Rotate: true, Swap: true},
+			want:    map[string][]string{engine.Blue: {"ro", "rs"}, engine.Red: {"bo1", "bo2", "bs"}},
+		},
+		{
+			name:    "rotate without the next spymaster",
+			rematch: Rematch{Rotate: true},
+			absent:  []string{"bo1"},
+			want:    map[string][]string{engine.Blue: {"bo2", "bs"}, engine.Red: {"ro", "rs"}},
+		},
+		{
+			name:    "swap three teams",
+			rematch: Rematch{Swap: true},
+			teams:   3,
+			want: map[string][]string{
+				engine.Blue:  {"gs", "go"},
+				engine.Red:   {"bs", "bo1", "bo2"},
+				engine.Green: {"rs", "ro"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := engine.DefaultSettings()
+			if tt.teams == 3 {
+				s.NumTeams = 3
+			}
+			old := engine.New(nil, s)
+			seats := map[string][]string{
+				engine.Blue:  {"bs", "bo1", "bo2"},
+				engine.Red:   {"rs", "ro"},
+				engine.Green: {"gs", "go"},
+			}
+			for _, color := range s.TeamColors() {
+				for i, nickname := range seats[color] {
+					p := &engine.Player{ID: "id-" + nickname, Nickname: nickname, Team: color, Role: engine.Operative}
+					if i == 0 {
+						p.Role = engine.Spymaster
+					}
+					if err := old.Seat(p); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			next := engine.New(nil, s)
+			moved := reseat(old, next, &tt.rematch, func(p *engine.Player) bool {
+				return !slices.Contains(tt.absent, p.Nickname)
+			})
+			got := seatsOf(next)
+			for _, color := range s.TeamColors() {
+				if !slices.Equal(got[color], tt.want[color]) {
+					t.Errorf("%s: %v, want %v", color, got[color], tt.want[color])
+				}
+			}
+
+			// every player who has come along is found by their old ID under a new one
+			for _, p := range next.Players() {
+				id, ok := moved["id-"+p.Nickname]
+				if !ok || id != p.ID || id == "id-"+p.Nickname {
+					t.Errorf("%s has the seat %q, the map gives %q", p.Nickname, p.ID, id)
+				}
+			}
+			if len(moved) != len(next.Players()) {
+				t.Errorf("%d seats moved, but %d players are seated", len(moved), len(next.Players()))
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/kjedeligmann/codenames/engine"
+	"github.com/kjedeligmann/codenames/store"
+)
+
+// the order the teams go in on the scoreboard, duet wins and losses go last
+var scoreOrder = []string{engine.Blue, engine.Red, engine.Green, engine.Black}
+
+// record adds the finished game to the score of the room
+func (room *Room) record(g *engine.Game) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	s := &room.score
+	if s.Teams == nil {
+		s.Teams = map[string]*store.TeamScore{}
+	}
+	if s.Players == nil {
+		s.Players = map[string]*store.PlayerScore{}
+	}
+	team := func(color string) *store.TeamScore {
+		if s.Teams[color] == nil {
+			s.Teams[color] = &store.TeamScore{}
+		}
+		return s.Teams[color]
+	}
+
+	s.Games++
+	team(g.Winner).Wins++
+	for _, m := range g.History {
+		switch m.Kind {
+		case engine.MoveClue:
+			team(m.Team).Clues++
+		case engine.MoveGuess:
+			team(m.Team).Guesses++
+			if m.Color == engine.Black {
+				team(m.Team).Assassins++
+			}
+		}
+	}
+	for _, p := range g.Players() {
+		if s.Players[p.Nickname] == nil {
+			s.Players[p.Nickname] = &store.PlayerScore{}
+		}
+		score := s.Players[p.Nickname]
+		score.Games++
+		// in duet everyone wins or loses together
+		if p.Team == g.Winner || g.Mode == engine.Duet && g.Winner == engine.Green {
+			score.Wins++
+		}
+	}
+	room.save()
+}
+
+// Scoreboard is the score of a room as the game page shows it and /room/{id}/score serves it
+type Scoreboard struct {
+	Room    string
+	Games   int
+	Teams   []TeamRow
+	Players []PlayerRow
+}
+
+type TeamRow struct {
+	Team string
+	store.TeamScore
+	GuessesPerClue float64
+}
+
+type PlayerRow struct {
+	Nickname string
+	store.PlayerScore
+}
+
+func (room *Room) scoreboard() Scoreboard {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	board := Scoreboard{Room: room.ID, Games: room.score.Games}
+	for color, score := range room.score.Teams {
+		row := TeamRow{Team: color, TeamScore: *score}
+		if score.Clues > 0 {
+			row.GuessesPerClue = float64(score.Guesses) / float64(score.Clues)
+		}
+		board.Teams = append(board.Teams, row)
+	}
+	slices.SortFunc(board.Teams, func(a, b TeamRow) int {
+		return cmp.Compare(slices.Index(scoreOrder, a.Team), slices.Index(scoreOrder, b.Team))
+	})
+	for nickname, score := range room.score.Players {
+		board.Players = append(board.Players, PlayerRow{Nickname: nickname, PlayerScore: *score})
+	}
+	// the ones who win the most go first
+	slices.SortFunc(board.Players, func(a, b PlayerRow) int {
+		return cmp.Or(
+			cmp.Compare(b.Wins, a.Wins),
+			cmp.Compare(a.Games, b.Games),
+			cmp.Compare(a.Nickname, b.Nickname),
+		)
+	})
+	return board
+}
+
+// Scoreboard is the score of the room the game is played in
+func (game *Game) Scoreboard() Scoreboard {
+	room, ok := findRoom(game.Room)
+	if !ok {
+		return Scoreboard{Room: game.Room}
+	}
+	return room.scoreboard()
+}
+
+// showScore adds the finished game to the score of its room and shows everyone the new score
+func (game *Game) showScore() {
+	if game.Room == "" {
+		return
+	}
+	room, ok := findRoom(game.Room)
+	if !ok {
+		log.Println("no room", game.Room)
+		return
+	}
+	room.record(game.Game)
+	scoreboard, err := renderNamed(template.Must(template.ParseFiles("scoreboard.html")), "scoreboard", room.scoreboard())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.broadcast(scoreboard)
+}
+
+// handleScore serves the score of the room as JSON
+func handleScore(mux *http.ServeMux) {
+	mux.HandleFunc("GET /room/{id}/score", func(w http.ResponseWriter, r *http.Request) {
+		room, ok := findRoom(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(room.scoreboard()); err != nil {
+			log.Println(err)
+			return
+		}
+	})
+}
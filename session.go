@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log"
+	"os"
+	"strings"
+)
+
+// secret signs the session tokens, so that nobody can take someone else's seat just by knowing their player ID
+var secret = newSecret()
+
+func newSecret() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+// loadSecret reads the secret from the file, or saves the current one there if there's no file yet
+func loadSecret(path string) error {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.WriteFile(path, secret, 0o600)
+	}
+	if err != nil {
+		return err
+	}
+	secret = key
+	return nil
+}
+
+// sign makes a session token for the player of the game
+func sign(gameID, playerID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(gameID + ":" + playerID))
+	return playerID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the ID of the player the token was issued for
+func verify(gameID, token string) (string, bool) {
+	playerID, _, ok := strings.Cut(token, ".")
+	if !ok || playerID == "" {
+		return "", false
+	}
+	return playerID, hmac.Equal([]byte(sign(gameID, playerID)), []byte(token))
+}
+
+// Resume is sent by the hub on behalf of a client that has come back with a session token
+type Resume struct {
+	Token string
+}
+
+// resume gives the seat back to the player who has reconnected and sends them their view of the game
+func (game *Game) resume(c *client, r *Resume) {
+	playerID, ok := verify(game.ID, r.Token)
+	if !ok {
+		log.Println("invalid session token")
+		return
+	}
+	pLock.RLock()
+	player, ok := players[playerID]
+	pLock.RUnlock()
+	if !ok || c.player != nil {
+		log.Println("Invalid player")
+		return
+	}
+
+	// the seat is taken over by the new connection, the old one (if it's still there) becomes a spectator
+	if player.client != nil {
+		player.client.player = nil
+	}
+	player.client = c
+	c.player = player
+	log.Println("player has reconnected", player.ID)
+
+	ownID, err := render(template.Must(template.New("ownID").Parse(OwnID)), game.session(player))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	game.sendTo(c, ownID)
+
+	// the nickname might have been lost along with the connection
+	if player.Nickname == "" {
+		enterNickname, err := render(template.Must(template.New("enter-nickname").Funcs(JoinFuncMap).Parse(EnterNickname)), player)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		game.sendTo(c, enterNickname)
+	}
+	game.catchUp(c)
+}
+
+// session is what the OwnID template needs to know about the player
+func (game *Game) session(p *Player) any {
+	return struct {
+		ID    string
+		Token string
+	}{p.ID, sign(game.ID, p.ID)}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files is a Store that keeps every game as a JSON file in the directory
+type Files struct {
+	Dir string
+}
+
+func NewFiles(dir string) (*Files, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Files{Dir: dir}, nil
+}
+
+// Save writes the record into a temporary file first,
+// so a crash in the middle of it doesn't leave a broken game behind
+func (f *Files) Save(r Record) error {
+	return write(f.Dir, r.ID, r)
+}
+
+// SaveRoom keeps the rooms in a directory of their own next to the games
+func (f *Files) SaveRoom(r RoomRecord) error {
+	dir := filepath.Join(f.Dir, "rooms")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return write(dir, r.ID, r)
+}
+
+// write saves the value as {id}.json in the directory, through a temporary file
+func write(dir, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, id+".json"))
+}
+
+func (f *Files) Load() ([]Record, error) {
+	list, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// a broken file shouldn't take the rest of the games down with it
+	var records []Record
+	var errs []error
+	for _, file := range list {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.Dir, file.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Name(), err))
+			continue
+		}
+		if r.Game == nil || r.Game.Board == nil {
+			errs = append(errs, fmt.Errorf("%s: incomplete game", file.Name()))
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, errors.Join(errs...)
+}
+
+func (f *Files) LoadRooms() ([]RoomRecord, error) {
+	dir := filepath.Join(f.Dir, "rooms")
+	list, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []RoomRecord
+	var errs []error
+	for _, file := range list {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var r RoomRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Name(), err))
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+// Package store keeps the games and rooms on disk, so that they survive a restart of the server
+package store
+
+import (
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+// Record is everything about a game that has to outlive the server
+type Record struct {
+	ID   string
+	Room string `json:",omitempty"`
+	Game *engine.Game
+}
+
+// Wordlist is a wordlist the boards of a room are made from, with its share of the words
+type Wordlist struct {
+	Name   string
+	Weight int
+}
+
+// RoomRecord is everything about a room that has to outlive the server
+type RoomRecord struct {
+	ID string
+	// IDs of the games of the room, the last one is the latest
+	Games []string
+	// the words that have been on the boards since the deck was reset
+	Used []string `json:",omitempty"`
+	// what the next board is made from: the wordlists, or a picture set
+	Wordlists []Wordlist `json:",omitempty"`
+	Pictures  string     `json:",omitempty"`
+	Score     Score
+}
+
+// Score is the running score of the games finished in a room
+type Score struct {
+	Games int
+	// by the color of the team, in duet the players win as "green" and lose as "black"
+	Teams map[string]*TeamScore `json:",omitempty"`
+	// by nickname, as the players get new IDs in every game
+	Players map[string]*PlayerScore `json:",omitempty"`
+}
+
+type TeamScore struct {
+	Wins int
+	// how many times the team has opened the assassin
+	Assassins int
+	Clues     int
+	Guesses   int
+}
+
+type PlayerScore struct {
+	Games int
+	Wins  int
+}
+
+// Store is where the games are saved after every move and loaded from on startup,
+// and so are the rooms after every change. Load and LoadRooms return whatever
+// they could read even if some of the records are broken
+type Store interface {
+	Save(r Record) error
+	Load() ([]Record, error)
+	SaveRoom(r RoomRecord) error
+	LoadRooms() ([]RoomRecord, error)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjedeligmann/codenames/store"
+)
+
+// how often the cache looks for wordlists that have changed on disk
+const reloadEvery = 5 * time.Second
+
+// cachedWordlist is a wordlist as it is kept in memory, along with the
+// modification times of its files to tell when it has to be read again
+type cachedWordlist struct {
+	info        WordlistInfo
+	modTime     time.Time
+	size        int64
+	manifestMod time.Time
+}
+
+// WordlistCache keeps every wordlist in memory, tidied up like the uploaded ones,
+// so that making a board doesn't touch the disk. It is safe for concurrent use,
+// the words it hands out are shared and must not be changed
+type WordlistCache struct {
+	mu    sync.RWMutex
+	lists map[string]*cachedWordlist
+
+	// only one reload at a time
+	reload sync.Mutex
+}
+
+var wordlistCache = &WordlistCache{lists: map[string]*cachedWordlist{}}
+
+// Get returns the wordlist with its manifest and words
+func (c *WordlistCache) Get(name string) (WordlistInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list, ok := c.lists[name]
+	if !ok {
+		return WordlistInfo{}, false
+	}
+	return list.info, true
+}
+
+// All returns every wordlist there is in the order of their names
+func (c *WordlistCache) All() []WordlistInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	infos := make([]WordlistInfo, 0, len(c.lists))
+	for _, list := range c.lists {
+		infos = append(infos, list.info)
+	}
+	slices.SortFunc(infos, func(a, b WordlistInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// Reload reads the wordlists that have been added or changed since the last time
+// and forgets the ones that are gone
+func (c *WordlistCache) Reload() error {
+	c.reload.Lock()
+	defer c.reload.Unlock()
+
+	entries, err := os.ReadDir("wordlists")
+	if err != nil {
+		return err
+	}
+	modTimes := map[string]time.Time{}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			modTimes[entry.Name()] = info.ModTime()
+		}
+	}
+
+	c.mu.RLock()
+	old := c.lists
+	c.mu.RUnlock()
+
+	lists := map[string]*cachedWordlist{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		manifestMod := modTimes[name+".json"]
+		if list, ok := old[name]; ok && list.modTime.Equal(stat.ModTime()) && list.size == stat.Size() && list.manifestMod.Equal(manifestMod) {
+			lists[name] = list
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("wordlists", entry.Name()))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		lists[name] = &cachedWordlist{
+			info: WordlistInfo{
+				Name:     name,
+				Manifest: readManifest(name),
+				Words:    tidyWords(string(data)),
+			},
+			modTime:     stat.ModTime(),
+			size:        stat.Size(),
+			manifestMod: manifestMod,
+		}
+		if old[name] != nil {
+			log.Println("reloaded wordlist", name)
+		}
+	}
+
+	c.mu.Lock()
+	c.lists = lists
+	c.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the wordlists every once in a while, so that the changes made to them
+// on disk show up without a restart
+func (c *WordlistCache) Watch(every time.Duration) {
+	for range time.Tick(every) {
+		if err := c.Reload(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+var ErrNotEnoughWords = errors.New("not enough words")
+
+// Wordlist is one of the wordlists a board is made from,
+// its words take a part of the board in proportion to the weight.
+// Rooms keep them, so it is the same as the one in the store
+type Wordlist = store.Wordlist
+
+// Source is what the boards are made from: a few wordlists, or a picture set
+type Source struct {
+	Wordlists []Wordlist
+	Pictures  string
+}
+
+// pick picks n words for a board from the source, or file names of pictures,
+// leaving out the excluded ones
+func (s Source) pick(n int, seed int64, exclude map[string]bool) ([]string, error) {
+	if s.Pictures != "" {
+		return Pictures(s.Pictures, n, seed, exclude)
+	}
+	return Words(s.Wordlists, n, seed, exclude)
+}
+
+// sampler draws the words of a deck at random, never the same one twice. It shuffles
+// the deck lazily without copying it, keeping only the places of the words moved so far
+type sampler struct {
+	deck  []string
+	rng   *rand.Rand
+	left  int
+	moved map[int]int
+}
+
+func newSampler(deck []string, rng *rand.Rand) *sampler {
+	return &sampler{deck: deck, rng: rng, left: len(deck), moved: map[int]int{}}
+}
+
+// at returns the index in the deck of the word that is now at i
+func (s *sampler) at(i int) int {
+	if j, ok := s.moved[i]; ok {
+		return j
+	}
+	return i
+}
+
+// next draws a word, it reports false when the deck has run out
+func (s *sampler) next() (string, bool) {
+	if s.left == 0 {
+		return "", false
+	}
+	i := s.rng.Intn(s.left)
+	s.left--
+	word := s.deck[s.at(i)]
+	// the last word not drawn yet takes the place of the drawn one
+	s.moved[i] = s.at(s.left)
+	return word, true
+}
+
+// shares splits n between the weights in proportion to them,
+// what is left after rounding down goes to the largest remainders
+func shares(n int, weights []int) []int {
+	var total int
+	for _, w := range weights {
+		total += w
+	}
+	parts := make([]int, len(weights))
+	if total == 0 {
+		return parts
+	}
+	left := n
+	for i, w := range weights {
+		parts[i] = n * w / total
+		left -= parts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		return n*weights[j]%total - n*weights[i]%total
+	})
+	for _, i := range order[:left] {
+		parts[i]++
+	}
+	return parts
+}
+
+// Words picks n random words from the wordlists, each of them gives its share of the words
+// by weight, and a word that is in a few of them gets on the board only once.
+// When a wordlist runs out of words, the others make up for it. The excluded words are left out.
+// The same seed picks the same words
+func Words(lists []Wordlist, n int, seed int64, exclude map[string]bool) ([]string, error) {
+	rng := rand.New(rand.NewSource(seed))
+
+	samplers := make([]*sampler, len(lists))
+	weights := make([]int, len(lists))
+	for i, list := range lists {
+		info, ok := wordlistCache.Get(list.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrWordlistNotFound, list.Name)
+		}
+		samplers[i] = newSampler(info.Words, rng)
+		weights[i] = list.Weight
+	}
+
+	words := make([]string, 0, n)
+	picked := map[string]bool{}
+	for word := range exclude {
+		picked[word] = true
+	}
+	// draw takes the next word of the wordlist that isn't on the board yet
+	draw := func(s *sampler) bool {
+		for {
+			word, ok := s.next()
+			if !ok {
+				return false
+			}
+			if !picked[word] {
+				picked[word] = true
+				words = append(words, word)
+				return true
+			}
+		}
+	}
+	for i, share := range shares(n, weights) {
+		for range share {
+			if !draw(samplers[i]) {
+				break
+			}
+		}
+	}
+	// the wordlists that have run out leave their part to the rest,
+	// which give it in proportion to how many words they have left
+	for len(words) < n {
+		var left int
+		for _, s := range samplers {
+			left += s.left
+		}
+		if left == 0 {
+			return nil, fmt.Errorf("%w: the wordlists have %d different words left, but the board needs %d", ErrNotEnoughWords, len(words), n)
+		}
+		k := rng.Intn(left)
+		for _, s := range samplers {
+			if k < s.left {
+				draw(s)
+				break
+			}
+			k -= s.left
+		}
+	}
+
+	rng.Shuffle(len(words), func(i, j int) {
+		words[i], words[j] = words[j], words[i]
+	})
+	return words, nil
+}
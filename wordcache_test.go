@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testWordlists puts the wordlists in the cache instead of the ones on disk,
+// each of them has n words named after it: a0, a1 and so on
+func testWordlists(sizes map[string]int) {
+	lists := map[string]*cachedWordlist{}
+	for name, n := range sizes {
+		var words []string
+		for i := range n {
+			words = append(words, fmt.Sprint(name, i))
+		}
+		lists[name] = &cachedWordlist{info: WordlistInfo{Name: name, Words: words}}
+	}
+	wordlistCache.mu.Lock()
+	wordlistCache.lists = lists
+	wordlistCache.mu.Unlock()
+}
+
+func TestWordsSeed(t *testing.T) {
+	testWordlists(map[string]int{"a": 40, "b": 40})
+
+	mix := []Wordlist{{Name: "a", Weight: 2}, {Name: "b", Weight: 1}}
+	words, err := Words(mix, 25, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Words(mix, 25, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(words, again) {
+		t.Errorf("the same seed picked %v and %v", words, again)
+	}
+	other, err := Words(mix, 25, 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(words, other) {
+		t.Errorf("different seeds picked the same words")
+	}
+}
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		n       int
+		weights []int
+		want    []int
+	}{
+		{25, []int{1}, []int{25}},
+		{25, []int{2, 1}, []int{17, 8}},
+		// 16.67 and 8.33, the larger remainder gets the word left over
+		{25, []int{1, 2}, []int{8, 17}},
+		// 0.83, 1.67 and 2.5, the two words left go to the two largest remainders
+		{5, []int{1, 2, 3}, []int{1, 2, 2}},
+		// equal remainders go in the order of the wordlists
+		{25, []int{1, 1, 1}, []int{9, 8, 8}},
+		{25, []int{70, 30}, []int{18, 7}},
+		{10, []int{1, 0}, []int{10, 0}},
+		{10, []int{0, 0}, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		got := shares(tt.n, tt.weights)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("shares(%d, %v) = %v, want %v", tt.n, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestWordsByWeight(t *testing.T) {
+	testWordlists(map[string]int{"a": 40, "b": 40, "c": 5})
+
+	tests := []struct {
+		name  string
+		lists []Wordlist
+		// how many of the words come from each wordlist
+		want map[string]int
+	}{
+		{
+			name:  "by weight",
+			lists: []Wordlist{{Name: "a", Weight: 2}, {Name: "b", Weight: 1}},
+			want:  map[string]int{"a": 17, "b": 8},
+		},
+		{
+			name:  "no weight",
+			lists: []Wordlist{{Name: "a", Weight: 1}, {Name: "b", Weight: 0}},
+			want:  map[string]int{"a": 25},
+		},
+		{
+			// c has 5 words for its share of 23, b makes up for the rest
+			name:  "smaller than its share",
+			lists: []Wordlist{{Name: "c", Weight: 9}, {Name: "b", Weight: 1}},
+			want:  map[string]int{"c": 5, "b": 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := range int64(20) {
+				words, err := Words(tt.lists, 25, seed, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := map[string]int{}
+				for _, word := range words {
+					got[strings.TrimRight(word, "0123456789")]++
+				}
+				if !maps.Equal(got, tt.want) {
+					t.Errorf("seed %d: words by wordlist %v, want %v", seed, got, tt.want)
+				}
+				if len(words) != 25 || len(uniq(words)) != 25 {
+					t.Errorf("seed %d: %d words, %d different, want 25", seed, len(words), len(uniq(words)))
+				}
+			}
+		})
+	}
+}
+
+func TestWordsNoRepeats(t *testing.T) {
+	testWordlists(map[string]int{"a": 20})
+	// the same wordlist twice has only 20 different words for the 25 cells
+	_, err := Words([]Wordlist{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}, 25, 1, nil)
+	if !errors.Is(err, ErrNotEnoughWords) {
+		t.Errorf("got %v, want %v", err, ErrNotEnoughWords)
+	}
+
+	words, err := Words([]Wordlist{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}, 15, 1, map[string]bool{"a0": true, "a1": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uniq(words)) != 15 || slices.Contains(words, "a0") || slices.Contains(words, "a1") {
+		t.Errorf("words %v, want 15 different words without the excluded ones", words)
+	}
+}
+
+func uniq(words []string) map[string]bool {
+	seen := map[string]bool{}
+	for _, word := range words {
+		seen[word] = true
+	}
+	return seen
+}
+
+func TestSampler(t *testing.T) {
+	deck := []string{"a", "b", "c", "d", "e", "f", "g"}
+	s := newSampler(deck, rand.New(rand.NewSource(1)))
+	var drawn []string
+	for {
+		word, ok := s.next()
+		if !ok {
+			break
+		}
+		drawn = append(drawn, word)
+	}
+	// every word once, and the deck itself stays as it was
+	slices.Sort(drawn)
+	if !slices.Equal(drawn, deck) {
+		t.Errorf("drew %v, want every word of %v once", drawn, deck)
+	}
+	if !slices.Equal(deck, []string{"a", "b", "c", "d", "e", "f", "g"}) {
+		t.Errorf("the deck has changed to %v", deck)
+	}
+}
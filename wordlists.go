@@ -0,0 +1,339 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kjedeligmann/codenames/engine"
+)
+
+// a wordlist needs at least as many different words as the classic board
+const minWords = engine.Size * engine.Size
+
+// uploads bigger than that are surely not wordlists
+const maxWordlistSize = 1 << 20
+
+// names of the wordlists are used in file names and form fields, so they are kept simple
+var wordlistName = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+var (
+	ErrWordlistExists   = errors.New("there is already a wordlist with this name")
+	ErrWordlistNotFound = errors.New("no such wordlist")
+)
+
+// how hard the words of a wordlist are to give clues for
+var difficulties = []string{"", "easy", "medium", "hard"}
+
+// Manifest describes a wordlist, it is kept next to the wordlist as {name}.json.
+// A wordlist without one goes by its file name
+type Manifest struct {
+	Name        string   `json:"name"`
+	Language    string   `json:"language,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Difficulty  string   `json:"difficulty,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Author      string   `json:"author,omitempty"`
+}
+
+// wordlistPath returns the file of the wordlist, or an error if the name can't be one
+func wordlistPath(name string) (string, error) {
+	if !wordlistName.MatchString(name) {
+		return "", fmt.Errorf("%q can't be the name of a wordlist, use letters, digits, - and _", name)
+	}
+	return filepath.Join("wordlists", name+".txt"), nil
+}
+
+// readManifest returns the manifest of the wordlist, a broken or missing one
+// is as good as none at all
+func readManifest(name string) Manifest {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join("wordlists", name+".json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println(err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Println(name, err)
+			m = Manifest{}
+		}
+	}
+	if m.Name == "" {
+		m.Name = name
+	}
+	return m
+}
+
+// saveManifest writes the manifest next to the wordlist
+func saveManifest(name string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join("wordlists", name+".json"), append(data, '\n'), 0o644)
+}
+
+// parseManifest reads the manifest of an uploaded wordlist from the form,
+// tags are separated by commas
+func parseManifest(r *http.Request, name string) (Manifest, error) {
+	m := Manifest{
+		Name:        strings.TrimSpace(r.FormValue("title")),
+		Language:    strings.TrimSpace(r.FormValue("language")),
+		Category:    strings.TrimSpace(r.FormValue("category")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Difficulty:  strings.TrimSpace(r.FormValue("difficulty")),
+		Author:      strings.TrimSpace(r.FormValue("author")),
+	}
+	if m.Name == "" {
+		m.Name = name
+	}
+	if !slices.Contains(difficulties, m.Difficulty) {
+		return m, fmt.Errorf("difficulty can be easy, medium or hard, not %q", m.Difficulty)
+	}
+	for _, tag := range strings.Split(r.FormValue("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(m.Tags, tag) {
+			m.Tags = append(m.Tags, tag)
+		}
+	}
+	return m, nil
+}
+
+// tidyWords splits the text of a wordlist into words: every word is trimmed,
+// blank lines and repeated words are dropped
+func tidyWords(text string) []string {
+	text = strings.TrimPrefix(text, "\uFEFF")
+
+	var words []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		word := strings.TrimSpace(line)
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return words
+}
+
+// cleanWordlist checks the text of an uploaded wordlist and tidies it up
+func cleanWordlist(data []byte) ([]string, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("the wordlist must be in UTF-8")
+	}
+	words := tidyWords(string(data))
+	if len(words) < minWords {
+		return nil, fmt.Errorf("the wordlist has %d different words, but it needs at least %d", len(words), minWords)
+	}
+	return words, nil
+}
+
+// saveWordlist writes the words down as a new wordlist, one per line
+func saveWordlist(name string, words []string) error {
+	path, err := wordlistPath(name)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return ErrWordlistExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(file, strings.Join(words, "\n")+"\n"); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// WordlistInfo is what the wordlist pages show about a wordlist
+type WordlistInfo struct {
+	Name     string
+	Manifest Manifest
+	Words    []string
+}
+
+// WordlistGroup is the wordlists in the same language
+type WordlistGroup struct {
+	Language  string
+	Wordlists []WordlistInfo
+}
+
+// groupWordlists puts the wordlists in groups by language, both the groups and the wordlists
+// in them go in alphabetical order, and the wordlists of no language go last
+func groupWordlists(infos []WordlistInfo) []WordlistGroup {
+	slices.SortStableFunc(infos, func(a, b WordlistInfo) int {
+		if (a.Manifest.Language == "") != (b.Manifest.Language == "") {
+			if a.Manifest.Language == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(
+			cmp.Compare(a.Manifest.Language, b.Manifest.Language),
+			cmp.Compare(a.Manifest.Name, b.Manifest.Name),
+		)
+	})
+	var groups []WordlistGroup
+	for _, info := range infos {
+		if len(groups) == 0 || groups[len(groups)-1].Language != info.Manifest.Language {
+			groups = append(groups, WordlistGroup{Language: info.Manifest.Language})
+		}
+		last := &groups[len(groups)-1]
+		last.Wordlists = append(last.Wordlists, info)
+	}
+	return groups
+}
+
+// handleWordlists adds the pages and the API to upload, download, preview and delete wordlists
+func handleWordlists(mux *http.ServeMux) {
+	mux.HandleFunc("GET /wordlists", func(w http.ResponseWriter, r *http.Request) {
+		page, err := render(template.Must(template.ParseFiles("wordlists.html")), wordlistCache.All())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		w.Write(page)
+	})
+
+	mux.HandleFunc("POST /wordlists", func(w http.ResponseWriter, r *http.Request) {
+		log.Println("post /wordlists")
+		r.Body = http.MaxBytesReader(w, r.Body, maxWordlistSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "no wordlist file, or it is too big", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		// the wordlist is named after the file if no name is given
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+		}
+		if _, err := wordlistPath(name); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "couldn't read the wordlist", http.StatusBadRequest)
+			return
+		}
+		words, err := cleanWordlist(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		manifest, err := parseManifest(r, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		err = saveWordlist(name, words)
+		if errors.Is(err, ErrWordlistExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "couldn't save the wordlist", http.StatusInternalServerError)
+			return
+		}
+		if err := saveManifest(name, manifest); err != nil {
+			// the wordlist is there anyway, it just goes by its file name
+			log.Println(err)
+		}
+		// no need to wait for the cache to notice it
+		if err := wordlistCache.Reload(); err != nil {
+			log.Println(err)
+		}
+
+		log.Println("uploaded wordlist", name, "with", len(words), "words")
+		preview := "/wordlists/" + name + "/preview"
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", preview)
+			return
+		}
+		http.Redirect(w, r, preview, http.StatusSeeOther)
+	})
+
+	mux.HandleFunc("GET /wordlists/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		path, err := wordlistPath(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			http.Error(w, ErrWordlistNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.txt"`, name))
+		http.ServeFile(w, r, path)
+	})
+
+	mux.HandleFunc("GET /wordlists/{name}/preview", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if _, err := wordlistPath(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		info, ok := wordlistCache.Get(name)
+		if !ok {
+			http.Error(w, ErrWordlistNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		page, err := render(template.Must(template.ParseFiles("wordlist.html")), info)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		w.Write(page)
+	})
+
+	mux.HandleFunc("DELETE /wordlists/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		path, err := wordlistPath(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		// the games that are already going have their words, so nothing else has to change
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, ErrWordlistNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "couldn't delete the wordlist", http.StatusInternalServerError)
+			return
+		}
+		if err := os.Remove(filepath.Join("wordlists", name+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println(err)
+		}
+		if err := wordlistCache.Reload(); err != nil {
+			log.Println(err)
+		}
+		log.Println("deleted wordlist", name)
+		// htmx swaps the row of the wordlist with nothing
+		w.WriteHeader(http.StatusOK)
+	})
+}